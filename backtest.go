@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/backtest"
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+// runBacktest — подкоманда backtest: прогон правила сигнала по истории свечей.
+//
+//	rsi-bot backtest -config config.json -symbols BTCUSDT,ETHUSDT -from 2024-01-01 -horizons 1,4,24 -out report.csv
+func runBacktest(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file with indicator params, thresholds and signal_rules (defaults if empty)")
+	symbolsFlag := fs.String("symbols", "", "comma-separated symbols; with -data defaults to all symbols in the file")
+	timeframe := fs.String("timeframe", "", "candle timeframe (defaults to config timeframe)")
+	mode := fs.String("mode", "", "signal mode: upper, lower or both (defaults to config signal_mode)")
+	fromFlag := fs.String("from", "", "range start, YYYY-MM-DD or RFC 3339 (required without -data)")
+	toFlag := fs.String("to", "", "range end, YYYY-MM-DD or RFC 3339 (defaults to now)")
+	dataPath := fs.String("data", "", "read candles from CSV instead of Bybit")
+	horizonsFlag := fs.String("horizons", "1,4,12,24", "comma-separated forward return horizons in bars")
+	outPath := fs.String("out", "", "write signals to .csv or .json file")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg := config.Default()
+	if *configPath != "" {
+		if _, err := os.Stat(*configPath); err != nil {
+			return err
+		}
+		store, err := config.Load(*configPath)
+		if err != nil {
+			return err
+		}
+		cfg = store.Get()
+	}
+	if *timeframe == "" {
+		*timeframe = cfg.Timeframe
+	}
+	if !config.ValidTimeframe(*timeframe) {
+		return fmt.Errorf("неподдерживаемый таймфрейм %q", *timeframe)
+	}
+	profile := cfg.DefaultProfile()
+	switch *mode {
+	case "":
+	case subscribers.ModeUpper, subscribers.ModeLower, subscribers.ModeBoth:
+		profile.Mode = *mode
+	default:
+		return fmt.Errorf("неизвестный режим %q", *mode)
+	}
+
+	horizons, err := parseHorizons(*horizonsFlag)
+	if err != nil {
+		return err
+	}
+	from, err := parseDate(*fromFlag, time.Time{})
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	to, err := parseDate(*toFlag, time.Now())
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	symbols := splitList(*symbolsFlag)
+
+	var history map[string][]exchange.Candle
+	if *dataPath != "" {
+		history, err = backtest.LoadCSV(*dataPath)
+		if err != nil {
+			return err
+		}
+		if len(symbols) == 0 {
+			for symbol := range history {
+				symbols = append(symbols, symbol)
+			}
+			sort.Strings(symbols)
+		}
+	} else {
+		if len(symbols) == 0 || from.IsZero() {
+			return errors.New("укажите -symbols и -from или файл -data")
+		}
+		fmt.Fprintf(os.Stderr, "Загрузка истории Bybit: %d символов, таймфрейм %s\n", len(symbols), *timeframe)
+		history, err = backtest.Fetch(exchange.NewBybit(), symbols, *timeframe, from, to, cfg.CandleLimit)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := backtest.Options{Config: cfg, Profile: profile, Horizons: horizons, From: from}
+	var signals []backtest.Signal
+	for _, symbol := range symbols {
+		candles := history[symbol]
+		for len(candles) > 0 && candles[len(candles)-1].OpenTime.After(to) {
+			candles = candles[:len(candles)-1]
+		}
+		signals = append(signals, backtest.Replay(symbol, *timeframe, candles, opts)...)
+	}
+
+	if *outPath != "" {
+		if err := writeReport(*outPath, signals, horizons); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Сигналов: %d (режим %s, таймфрейм %s, символов %d)\n\n", len(signals), profile.Mode, *timeframe, len(symbols))
+	return backtest.WriteSummary(os.Stdout, backtest.Summarize(signals, horizons))
+}
+
+func writeReport(path string, signals []backtest.Signal, horizons []int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = backtest.WriteJSON(f, signals)
+	default:
+		err = backtest.WriteCSV(f, signals, horizons)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func parseHorizons(raw string) ([]int, error) {
+	var out []int
+	for _, item := range splitList(raw) {
+		h, err := strconv.Atoi(item)
+		if err != nil || h <= 0 {
+			return nil, fmt.Errorf("-horizons: некорректный горизонт %q", item)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func parseDate(raw string, fallback time.Time) (time.Time, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.ToUpper(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
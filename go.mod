@@ -2,4 +2,8 @@ module grevtsevalex/crypto-bot
 
 go 1.24
 
-require github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+require (
+	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/image v0.25.0
+)
@@ -0,0 +1,165 @@
+// Package backtest прогоняет историю свечей бар за баром через расчёт RSI/Stoch RSI
+// и правило сигнала бота, чтобы увидеть, какие сигналы пришли бы в прошлом
+// и как цена вела себя после них.
+package backtest
+
+import (
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/rules"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+// Options — параметры прогона.
+type Options struct {
+	// Config задаёт периоды индикаторов, candle_limit, stoch_lower_slack и signal_rules.
+	Config config.Config
+	// Profile — режим и пороги, по которым проверяется сигнал (как у подписчика).
+	Profile subscribers.Profile
+	// Horizons — через сколько баров после сигнала считать доходность.
+	Horizons []int
+	// From — сигналы на барах раньше From не попадают в отчёт; бары до него только прогревают индикаторы.
+	From time.Time
+}
+
+// Forward — изменение цены закрытия через Bars баров после сигнала, в процентах.
+type Forward struct {
+	Bars   int     `json:"bars"`
+	Return float64 `json:"return_pct"`
+}
+
+// Signal — сигнал, который бот отправил бы на закрытии свечи.
+type Signal struct {
+	Symbol    string    `json:"symbol"`
+	Timeframe string    `json:"timeframe"`
+	BarTime   time.Time `json:"bar_time"`
+	Mode      string    `json:"mode"`
+	Close     float64   `json:"close"`
+	RSI       float64   `json:"rsi"`
+	RawK      float64   `json:"raw_k"`
+	K         float64   `json:"k"`
+	D         float64   `json:"d"`
+	// Forward содержит только горизонты, для которых в истории хватило баров.
+	Forward []Forward `json:"forward"`
+}
+
+// Replay проверяет каждую закрытую свечу так же, как бот в режиме bar_mode=closed:
+// индикаторы считаются потоково от начала истории, правила видят последние candle_limit
+// цен, сигнал сверяется с профилем
+// или правилами из конфига, а повтор внутри одного захода в зону подавляется так же,
+// как дедупликация нотификатора.
+func Replay(symbol, timeframe string, candles []exchange.Candle, opts Options) []Signal {
+	c := opts.Config
+	candles = exchange.ClosedCandles(candles)
+	closes := exchange.Closes(candles)
+	// active — режим текущего захода в зону; пусто, пока индикаторы вне зоны.
+	active := ""
+	// Потоковый калькулятор считает бар за O(stoch_period), а не пересчитывает окно
+	// из candle_limit цен на каждом баре истории.
+	stoch := rsi.NewStochRSI(c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD)
+
+	var signals []Signal
+	for i := range candles {
+		values := stoch.Next(closes[i])
+		if i < c.WarmupBars()-1 {
+			continue
+		}
+		window := closes[max(0, i+1-c.CandleLimit) : i+1]
+
+		var ruleResults map[string]bool
+		if len(c.SignalRules) > 0 {
+			src := rules.NewIndicatorSource(window, c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD, values)
+			ruleResults = make(map[string]bool, len(c.SignalRules))
+			for mode, rule := range c.SignalRules {
+				ruleResults[mode] = rule.Eval(src)
+			}
+		}
+
+		mode, ok := opts.Profile.Evaluate(values, c.StochLowerSlack, ruleResults)
+		if !ok {
+			active = ""
+			continue
+		}
+		if mode == active {
+			continue
+		}
+		active = mode
+		if candles[i].OpenTime.Before(opts.From) {
+			continue
+		}
+
+		sig := Signal{
+			Symbol:    symbol,
+			Timeframe: timeframe,
+			BarTime:   candles[i].OpenTime,
+			Mode:      mode,
+			Close:     closes[i],
+			RSI:       values.RSI,
+			RawK:      values.RawK,
+			K:         values.K,
+			D:         values.D,
+		}
+		for _, h := range opts.Horizons {
+			if h > 0 && i+h < len(closes) && closes[i] != 0 {
+				sig.Forward = append(sig.Forward, Forward{Bars: h, Return: (closes[i+h]/closes[i] - 1) * 100})
+			}
+		}
+		signals = append(signals, sig)
+	}
+	return signals
+}
+
+// Stat — сводка по сигналам одного режима на одном горизонте.
+type Stat struct {
+	Mode      string
+	Bars      int
+	Signals   int     // сигналов режима всего
+	Count     int     // сигналов, для которых известна доходность на горизонте
+	AvgReturn float64 // средняя доходность, %
+	WinRate   float64 // доля сигналов, после которых цена пошла в ожидаемую сторону, %
+}
+
+// Summarize считает среднюю доходность и долю удачных сигналов по режимам и горизонтам.
+// Для верхней зоны удачным считается снижение цены, для нижней — рост.
+func Summarize(signals []Signal, horizons []int) []Stat {
+	var stats []Stat
+	for _, mode := range []string{subscribers.ModeUpper, subscribers.ModeLower} {
+		total := 0
+		for _, s := range signals {
+			if s.Mode == mode {
+				total++
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		for _, h := range horizons {
+			st := Stat{Mode: mode, Bars: h, Signals: total}
+			wins := 0
+			for _, s := range signals {
+				if s.Mode != mode {
+					continue
+				}
+				for _, f := range s.Forward {
+					if f.Bars != h {
+						continue
+					}
+					st.Count++
+					st.AvgReturn += f.Return
+					if (mode == subscribers.ModeUpper && f.Return < 0) || (mode == subscribers.ModeLower && f.Return > 0) {
+						wins++
+					}
+				}
+			}
+			if st.Count > 0 {
+				st.AvgReturn /= float64(st.Count)
+				st.WinRate = float64(wins) / float64(st.Count) * 100
+			}
+			stats = append(stats, st)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,98 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/rules"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+func candlesFromCloses(closes []float64) []exchange.Candle {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out := make([]exchange.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = exchange.Candle{OpenTime: start.Add(time.Duration(i) * time.Hour), Close: c, Confirmed: true}
+	}
+	return out
+}
+
+func TestReplayDedupsZoneAndComputesForwardReturns(t *testing.T) {
+	cfg := config.Default()
+	var rule rules.Rule
+	if err := json.Unmarshal([]byte(`{"left": "close", "op": ">=", "right": 100}`), &rule); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SignalRules = map[string]*rules.Rule{"upper": &rule}
+
+	var closes []float64
+	for i := 0; i < 40; i++ {
+		closes = append(closes, 90)
+	}
+	// Два захода в зону: первый длится три бара и даёт один сигнал, второй — ещё один.
+	closes = append(closes, 100, 101, 102, 95, 90, 110, 99)
+	candles := candlesFromCloses(closes)
+
+	signals := Replay("BTCUSDT", "60", candles, Options{Config: cfg, Profile: cfg.DefaultProfile(), Horizons: []int{1, 3}})
+	if len(signals) != 2 {
+		t.Fatalf("Replay() = %d signals, want 2: %+v", len(signals), signals)
+	}
+	first, second := signals[0], signals[1]
+	if !first.BarTime.Equal(candles[40].OpenTime) || first.Mode != subscribers.ModeUpper || first.Close != 100 {
+		t.Fatalf("first signal = %+v", first)
+	}
+	// Индикаторы считаются потоково от начала истории — так же, как пересчёт всех цен до бара.
+	if want := rsi.CalcStochRSI(closes[:41], cfg.RSIPeriod, cfg.StochPeriod, cfg.SmoothK, cfg.SmoothD); first.RSI != want.RSI || first.K != want.K || first.D != want.D {
+		t.Fatalf("first signal values = %+v, want %+v", first, want)
+	}
+	if len(first.Forward) != 2 || math.Abs(first.Forward[0].Return-1) > 1e-9 || math.Abs(first.Forward[1].Return+5) > 1e-9 {
+		t.Fatalf("first forward = %+v, want +1%% and -5%%", first.Forward)
+	}
+	if len(second.Forward) != 1 || second.Forward[0].Bars != 1 {
+		t.Fatalf("second forward = %+v, want only the 1-bar horizon", second.Forward)
+	}
+
+	stats := Summarize(signals, []int{1, 3})
+	if len(stats) != 2 || stats[0].Count != 2 || stats[0].WinRate != 50 || stats[1].Count != 1 || stats[1].WinRate != 100 {
+		t.Fatalf("Summarize() = %+v", stats)
+	}
+
+	late := Replay("BTCUSDT", "60", candles, Options{Config: cfg, Profile: cfg.DefaultProfile(), From: candles[41].OpenTime})
+	if len(late) != 1 || !late[0].BarTime.Equal(candles[45].OpenTime) {
+		t.Fatalf("Replay() with From = %+v, want only the second zone entry", late)
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candles.csv")
+	data := "symbol,open_time,open,high,low,close\n" +
+		"ethusdt,2024-01-01T01:00:00Z,2,2,2,2\n" +
+		"ETHUSDT,1704067200000,1,1,1,1\n" +
+		"ETHUSDT,2024-01-01T01:00:00Z,3,3,3,3\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV() error: %v", err)
+	}
+	candles := history["ETHUSDT"]
+	if len(candles) != 2 || candles[0].Close != 1 || candles[1].Close != 3 || !candles[1].Confirmed {
+		t.Fatalf("LoadCSV() = %+v, want sorted candles with the last duplicate kept", candles)
+	}
+
+	if err := os.WriteFile(path, []byte("symbol,open_time,close\nBTCUSDT,1,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCSV(path); err == nil {
+		t.Fatal("LoadCSV() should reject a file without OHLC columns")
+	}
+}
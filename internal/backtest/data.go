@@ -0,0 +1,136 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+)
+
+// Fetch загружает с Bybit историю по символам с открытием свечей в [from, to].
+// Чтобы индикаторы на первом баре диапазона были прогреты, история начинается
+// на warmup свечей раньше from.
+func Fetch(ex *exchange.BybitExchange, symbols []string, timeframe string, from, to time.Time, warmup int) (map[string][]exchange.Candle, error) {
+	start := from.Add(-time.Duration(warmup) * exchange.TimeframeDuration(timeframe))
+	out := make(map[string][]exchange.Candle, len(symbols))
+	for _, symbol := range symbols {
+		candles, err := ex.CandlesRange(symbol, timeframe, start, to)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", symbol, err)
+		}
+		out[symbol] = candles
+	}
+	return out, nil
+}
+
+// LoadCSV читает свечи из CSV-файла с заголовком. Обязательные колонки: symbol, open_time,
+// open, high, low, close; volume и turnover — по желанию. open_time — миллисекунды Unix
+// или RFC 3339. Все свечи из файла считаются закрытыми.
+func LoadCSV(path string) (map[string][]exchange.Candle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: заголовок: %w", path, err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"symbol", "open_time", "open", "high", "low", "close"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("%s: нет колонки %s", path, name)
+		}
+	}
+
+	out := make(map[string][]exchange.Candle)
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		symbol := strings.ToUpper(row[col["symbol"]])
+		c, err := parseRow(row, col)
+		if err != nil {
+			return nil, fmt.Errorf("%s: строка %d: %w", path, line, err)
+		}
+		out[symbol] = append(out[symbol], c)
+	}
+
+	for symbol, candles := range out {
+		sort.SliceStable(candles, func(i, j int) bool { return candles[i].OpenTime.Before(candles[j].OpenTime) })
+		// Повторы одной свечи оставляем в последнем варианте.
+		deduped := candles[:0]
+		for _, c := range candles {
+			if n := len(deduped); n > 0 && deduped[n-1].OpenTime.Equal(c.OpenTime) {
+				deduped[n-1] = c
+				continue
+			}
+			deduped = append(deduped, c)
+		}
+		out[symbol] = deduped
+	}
+	return out, nil
+}
+
+func parseRow(row []string, col map[string]int) (exchange.Candle, error) {
+	openTime, err := parseTime(row[col["open_time"]])
+	if err != nil {
+		return exchange.Candle{}, err
+	}
+	c := exchange.Candle{OpenTime: openTime, Confirmed: true}
+	fields := []struct {
+		name     string
+		value    *float64
+		optional bool
+	}{
+		{"open", &c.Open, false},
+		{"high", &c.High, false},
+		{"low", &c.Low, false},
+		{"close", &c.Close, false},
+		{"volume", &c.Volume, true},
+		{"turnover", &c.Turnover, true},
+	}
+	for _, f := range fields {
+		i, ok := col[f.name]
+		if !ok && f.optional {
+			continue
+		}
+		if i >= len(row) {
+			return exchange.Candle{}, fmt.Errorf("нет поля %s", f.name)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+		if err != nil {
+			return exchange.Candle{}, fmt.Errorf("поле %s=%q: %w", f.name, row[i], err)
+		}
+		*f.value = v
+	}
+	return c, nil
+}
+
+func parseTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("поле open_time=%q: ожидались миллисекунды или RFC 3339", raw)
+	}
+	return t, nil
+}
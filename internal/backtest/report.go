@@ -0,0 +1,68 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteCSV пишет сигналы построчно: по колонке доходности на каждый горизонт,
+// пустая ячейка — после сигнала не хватило баров.
+func WriteCSV(w io.Writer, signals []Signal, horizons []int) error {
+	cw := csv.NewWriter(w)
+	header := []string{"symbol", "timeframe", "bar_time", "mode", "close", "rsi", "raw_k", "k", "d"}
+	for _, h := range horizons {
+		header = append(header, fmt.Sprintf("ret_%d", h))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range signals {
+		row := []string{
+			s.Symbol, s.Timeframe, s.BarTime.UTC().Format(time.RFC3339), s.Mode,
+			formatFloat(s.Close), formatFloat(s.RSI), formatFloat(s.RawK), formatFloat(s.K), formatFloat(s.D),
+		}
+		for _, h := range horizons {
+			cell := ""
+			for _, f := range s.Forward {
+				if f.Bars == h {
+					cell = strconv.FormatFloat(f.Return, 'f', 4, 64)
+				}
+			}
+			row = append(row, cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON пишет сигналы массивом JSON.
+func WriteJSON(w io.Writer, signals []Signal) error {
+	if signals == nil {
+		signals = []Signal{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(signals)
+}
+
+// WriteSummary печатает сводку таблицей.
+func WriteSummary(w io.Writer, stats []Stat) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "mode\tbars\tsignals\twith return\tavg return %\twin rate %\t")
+	for _, st := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t%.1f\t\n", st.Mode, st.Bars, st.Signals, st.Count, st.AvgReturn, st.WinRate)
+	}
+	return tw.Flush()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
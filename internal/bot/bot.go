@@ -0,0 +1,326 @@
+// Package bot собирает один Telegram-бот: конфиг, подписчиков, нотификатор и обработчики команд.
+// Несколько ботов работают в одном процессе и получают свечи от общего сканера.
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/delivery"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/handlers"
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/leaderboard"
+	"grevtsevalex/crypto-bot/internal/notify"
+	"grevtsevalex/crypto-bot/internal/outcomes"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/rules"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+	"grevtsevalex/crypto-bot/internal/universe"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// historyArchives — сколько ротированных файлов журнала сигналов хранить.
+	historyArchives = 5
+	// outcomesInterval — период проверки результатов сигналов.
+	outcomesInterval = time.Minute
+)
+
+// Bot — один Telegram-бот со своим токеном, конфигом и подписчиками.
+type Bot struct {
+	name     string
+	cfg      *config.Store
+	api      *tgbotapi.BotAPI
+	subs     *subscribers.Store
+	queue    *delivery.Queue
+	history  *history.Store
+	outcomes *outcomes.Tracker
+	notifier *notify.Notifier
+	board    *leaderboard.Board // значения индикаторов последнего прохода сканера для /top
+	sinkTFs  []string           // таймфреймы каналов доставки из конфига
+	handler  *handlers.Handler
+	done     chan struct{}
+
+	barsMu  sync.Mutex
+	lastBar map[string]time.Time // последняя проверенная закрытая свеча по symbol|timeframe
+
+	streamMu   sync.Mutex
+	indicators map[string]*indicatorStream // потоковые индикаторы data_source=stream по symbol|timeframe
+}
+
+// indicatorStream — потоковый Stoch RSI одной пары: обновление свечи из WebSocket
+// пересчитывает только последний бар, а не весь ряд из candle_limit цен.
+type indicatorStream struct {
+	calc    *rsi.StochRSI
+	periods [4]int    // rsi_period, stoch_period, smooth_k, smooth_d, с которыми создан calc
+	lastBar time.Time // время открытия последнего поданного бара
+}
+
+// New подключается к Telegram по токену из конфига и загружает подписчиков.
+// ex — адаптер биржи из конфига, общий для всех ботов этой биржи и сканера.
+func New(cfg *config.Store, ex exchange.Exchange) (*Bot, error) {
+	c := cfg.Get()
+	b := &Bot{
+		name:       cfg.Path(),
+		cfg:        cfg,
+		lastBar:    make(map[string]time.Time),
+		indicators: make(map[string]*indicatorStream),
+		done:       make(chan struct{}),
+	}
+
+	b.subs = subscribers.NewStore(c.SubscribersFile, b.defaultProfile)
+	if err := b.subs.Load(); err != nil {
+		log.Printf("[%s] Ошибка загрузки подписчиков: %v", b.name, err)
+	} else {
+		log.Printf("[%s] Загружено %d подписчиков", b.name, b.subs.Len())
+	}
+
+	api, err := tgbotapi.NewBotAPI(c.TelegramToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: ошибка инициализации бота: %w", b.name, err)
+	}
+	b.api = api
+	b.history = history.New(c.HistoryFile, int64(c.HistoryMaxSizeMB)<<20, historyArchives)
+	b.queue = delivery.New(api, delivery.Options{})
+	b.notifier = notify.New(b.queue, b.subs, b.history)
+	if err := b.notifier.LoadState(c.SignalStateFile, c.SignalStateExpiryBars); err != nil {
+		log.Printf("[%s] Ошибка загрузки состояния сигналов: %v", b.name, err)
+	}
+	for _, sc := range c.Sinks {
+		sink, err := notify.NewSink(sc, b.queue)
+		if err != nil {
+			return nil, fmt.Errorf("%s: канал %s: %w", b.name, sc.Name, err)
+		}
+		profile := c.SinkProfile(sc)
+		b.notifier.AddRoute(notify.Route{Sink: sink, Profile: profile})
+		b.sinkTFs = append(b.sinkTFs, profile.Timeframes...)
+	}
+	b.outcomes = outcomes.NewTracker(ex, b.history, c.OutcomeHorizons, c.OutcomesFile)
+	if err := b.outcomes.Load(); err != nil {
+		log.Printf("[%s] Ошибка загрузки результатов сигналов: %v", b.name, err)
+	}
+	b.board = leaderboard.New()
+	b.handler = handlers.New(api, b.queue, cfg, b.subs, b.outcomes, ex, b.board)
+	return b, nil
+}
+
+// Name возвращает имя бота для логов (путь к его конфигу).
+func (b *Bot) Name() string {
+	return b.name
+}
+
+// Start запускает обработку команд Telegram и отслеживание результатов сигналов в отдельных горутинах.
+func (b *Bot) Start() {
+	go b.handler.HandleUpdates()
+	go b.trackOutcomes()
+}
+
+// Stop прекращает получение обновлений Telegram, дожидается рассылки уже поставленных
+// сигналов, останавливает очередь отправки и сохраняет состояние сигналов.
+func (b *Bot) Stop() {
+	b.api.StopReceivingUpdates()
+	close(b.done)
+	b.notifier.Close()
+	b.queue.Stop()
+	if err := b.notifier.SaveState(); err != nil {
+		log.Printf("[%s] Ошибка сохранения состояния сигналов: %v", b.name, err)
+	}
+}
+
+// History возвращает журнал разосланных сигналов бота.
+func (b *Bot) History() *history.Store {
+	return b.history
+}
+
+// Exchange возвращает биржу, по свечам которой бот считает сигналы.
+func (b *Bot) Exchange() string {
+	return b.cfg.Get().Exchange
+}
+
+// DataSource возвращает источник свечей: poll или stream.
+func (b *Bot) DataSource() string {
+	return b.cfg.Get().DataSource
+}
+
+// Timeframes возвращает таймфреймы, нужные подписчикам и каналам доставки бота.
+func (b *Bot) Timeframes() []string {
+	tfs := b.subs.Timeframes()
+	for _, tf := range b.sinkTFs {
+		if !slices.Contains(tfs, tf) {
+			tfs = append(tfs, tf)
+		}
+	}
+	return tfs
+}
+
+// CandleLimit возвращает число свечей для расчёта индикаторов.
+func (b *Bot) CandleLimit() int {
+	return b.cfg.Get().CandleLimit
+}
+
+// MaxSignalsPerCycle возвращает лимит уведомлений за цикл сканера или, в потоке, за свечу таймфрейма.
+func (b *Bot) MaxSignalsPerCycle() int {
+	return b.cfg.Get().MaxSignalsPerCycle
+}
+
+// Universe возвращает фильтр пар, которые сканирует бот.
+func (b *Bot) Universe() universe.Filter {
+	return b.cfg.Get().Universe
+}
+
+// ProcessCandles считает Bybit-подобные RSI и Stoch RSI по свечам, обновляет /top
+// и, если send, передаёт значения нотификатору, который сверяет их с профилями подписчиков.
+// В режиме bar_mode=closed незакрытая свеча отбрасывается, а каждая закрытая
+// проверяется на сигнал ровно один раз; свеча, посчитанная без send, проверится
+// при следующем проходе. В режиме data_source=stream индикаторы считаются потоково,
+// см. streamValues. Возвращает true, если уведомление было отправлено.
+func (b *Bot) ProcessCandles(symbol, timeframe string, candles []exchange.Candle, send bool) bool {
+	c := b.cfg.Get()
+	if c.BarMode == "closed" {
+		candles = exchange.ClosedCandles(candles)
+		if len(candles) == 0 {
+			return false
+		}
+		openTime := candles[len(candles)-1].OpenTime
+		if send && !b.markEvaluated(symbol, timeframe, openTime) || !send && b.evaluated(symbol, timeframe, openTime) {
+			return false
+		}
+	}
+	// У только что залистенной пары истории меньше, чем нужно фильтру: индикаторы ещё не устоялись.
+	if len(candles) == 0 || !c.Universe.EnoughBars(symbol, len(candles)) {
+		return false
+	}
+
+	closes := exchange.Closes(candles)
+	var values rsi.StochRSIValues
+	if c.DataSource == "stream" {
+		values = b.streamValues(symbol, timeframe, candles, c)
+	} else {
+		values = rsi.CalcStochRSI(closes, c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD)
+	}
+	log.Printf(
+		"[%s] %s RSI(%s,%d)=%.2f Stoch RSI(%d,%d,%d) raw=%.2f K=%.2f D=%.2f",
+		b.name, symbol, timeframe, c.RSIPeriod, values.RSI, c.StochPeriod, c.SmoothK, c.SmoothD, values.RawK, values.K, values.D,
+	)
+
+	b.board.Update(timeframe, leaderboard.Entry{
+		Symbol:  symbol,
+		Price:   closes[len(closes)-1],
+		Values:  values,
+		BarTime: candles[len(candles)-1].OpenTime,
+		Updated: time.Now(),
+	})
+
+	if !send {
+		return false
+	}
+
+	var ruleResults map[string]bool
+	if len(c.SignalRules) > 0 {
+		src := rules.NewIndicatorSource(closes, c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD, values)
+		ruleResults = make(map[string]bool, len(c.SignalRules))
+		for mode, rule := range c.SignalRules {
+			ruleResults[mode] = rule.Eval(src)
+		}
+	}
+
+	chartBars := 0
+	if c.Chart {
+		chartBars = c.ChartBars
+	}
+	return b.notifier.SendSignal(notify.Signal{
+		Symbol:      symbol,
+		Timeframe:   timeframe,
+		BarTime:     candles[len(candles)-1].OpenTime,
+		Price:       candles[len(candles)-1].Close,
+		Values:      values,
+		RSIPeriod:   c.RSIPeriod,
+		StochPeriod: c.StochPeriod,
+		SmoothK:     c.SmoothK,
+		SmoothD:     c.SmoothD,
+		LowerKSlack: c.StochLowerSlack,
+		RuleResults: ruleResults,
+		Candles:     candles,
+		ChartBars:   chartBars,
+	})
+}
+
+// trackOutcomes раз в минуту подхватывает новые сигналы из журнала и досчитывает их результаты.
+func (b *Bot) trackOutcomes() {
+	ticker := time.NewTicker(outcomesInterval)
+	defer ticker.Stop()
+	for {
+		if err := b.outcomes.Update(time.Now()); err != nil {
+			log.Printf("[%s] Ошибка отслеживания результатов сигналов: %v", b.name, err)
+		}
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluated сообщает, проверялась ли уже закрытая свеча с этим временем открытия.
+func (b *Bot) evaluated(symbol, timeframe string, openTime time.Time) bool {
+	b.barsMu.Lock()
+	defer b.barsMu.Unlock()
+	last, ok := b.lastBar[symbol+"|"+timeframe]
+	return ok && !openTime.After(last)
+}
+
+// markEvaluated запоминает последнюю проверенную закрытую свечу и возвращает false,
+// если свеча с этим временем открытия уже проверялась.
+func (b *Bot) markEvaluated(symbol, timeframe string, openTime time.Time) bool {
+	key := symbol + "|" + timeframe
+	b.barsMu.Lock()
+	defer b.barsMu.Unlock()
+	if last, ok := b.lastBar[key]; ok && !openTime.After(last) {
+		return false
+	}
+	b.lastBar[key] = openTime
+	return true
+}
+
+// streamValues подаёт свечи потоковому калькулятору пары и возвращает значения последнего бара.
+// Обновление той же свечи заменяет цену последнего бара, новая свеча сначала фиксирует
+// итоговую цену закрывшейся. Если свечи пропущены, ряд начался заново или в конфиге
+// сменились периоды, калькулятор заново прогоняется по всем переданным свечам.
+// Уайлдер сглаживает RSI от первого поданного бара, поэтому значения расходятся
+// с CalcStochRSI по окну из candle_limit свечей лишь на след начала ряда, который быстро затухает.
+func (b *Bot) streamValues(symbol, timeframe string, candles []exchange.Candle, c config.Config) rsi.StochRSIValues {
+	key := symbol + "|" + timeframe
+	periods := [4]int{c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD}
+	last := candles[len(candles)-1]
+
+	b.streamMu.Lock()
+	defer b.streamMu.Unlock()
+	st := b.indicators[key]
+	if st != nil && st.periods == periods {
+		if last.OpenTime.Equal(st.lastBar) {
+			return st.calc.Update(last.Close)
+		}
+		if n := len(candles); n > 1 && candles[n-2].OpenTime.Equal(st.lastBar) {
+			st.calc.Update(candles[n-2].Close)
+			st.lastBar = last.OpenTime
+			return st.calc.Next(last.Close)
+		}
+	}
+	st = &indicatorStream{calc: rsi.NewStochRSI(periods[0], periods[1], periods[2], periods[3]), periods: periods, lastBar: last.OpenTime}
+	for _, candle := range candles {
+		st.calc.Next(candle.Close)
+	}
+	b.indicators[key] = st
+	return st.calc.Values()
+}
+
+// defaultProfile возвращает профиль новых подписчиков по текущему конфигу.
+func (b *Bot) defaultProfile() subscribers.Profile {
+	return b.cfg.Get().DefaultProfile()
+}
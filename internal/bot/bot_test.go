@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/leaderboard"
+	"grevtsevalex/crypto-bot/internal/notify"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeSender принимает все сообщения и считает их.
+type fakeSender struct{ sent atomic.Int32 }
+
+func (f *fakeSender) Enqueue(_ int64, _ tgbotapi.Chattable, done func(tgbotapi.Message, error)) {
+	f.sent.Add(1)
+	go done(tgbotapi.Message{}, nil)
+}
+
+// newTestBot собирает бота без Telegram: конфиг из body, подписчик 1, которому подходит
+// любой сигнал, и нотификатор поверх fakeSender.
+func newTestBot(t *testing.T, body string) (*Bot, *fakeSender) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{
+		name:       "test",
+		cfg:        cfg,
+		board:      leaderboard.New(),
+		lastBar:    make(map[string]time.Time),
+		indicators: make(map[string]*indicatorStream),
+	}
+	b.subs = subscribers.NewStore(filepath.Join(dir, "subscribers.json"), b.defaultProfile)
+	if _, err := b.subs.Subscribe(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.subs.Update(1, func(p *subscribers.Profile) {
+		// Пороги перекрывают всю шкалу: значения вне верхней зоны попадают в нижнюю.
+		p.Mode = subscribers.ModeBoth
+		p.RSIUpper, p.StochUpper, p.RSILower, p.StochLower = 0.01, 0.01, 100, 100
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sender := &fakeSender{}
+	b.notifier = notify.New(sender, b.subs, history.New(filepath.Join(dir, "signals.jsonl"), 0, 0))
+	t.Cleanup(b.notifier.Close)
+	return b, sender
+}
+
+// testCandles возвращает closed закрытых часовых свечей и одну незакрытую после них.
+func testCandles(closed int) []exchange.Candle {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]exchange.Candle, closed+1)
+	for i := range candles {
+		price := 100 + 5*math.Sin(float64(i)/3) + float64(i)/10
+		candles[i] = exchange.Candle{
+			OpenTime:  start.Add(time.Duration(i) * time.Hour),
+			Open:      price,
+			High:      price + 1,
+			Low:       price - 1,
+			Close:     price,
+			Confirmed: i < closed,
+		}
+	}
+	return candles
+}
+
+func TestProcessCandlesEvaluatesClosedBarOnce(t *testing.T) {
+	type step struct {
+		closed int
+		send   bool
+		want   bool
+	}
+	for _, c := range []struct {
+		name    string
+		barMode string
+		steps   []step
+	}{
+		{"closed", "closed", []step{
+			{closed: 50, send: false, want: false}, // /top без проверки не занимает свечу
+			{closed: 50, send: true, want: true},
+			{closed: 50, send: true, want: false}, // та же закрытая свеча второй раз не проверяется
+			{closed: 50, send: false, want: false},
+			{closed: 51, send: false, want: false},
+			{closed: 51, send: true, want: true},
+		}},
+		{"intrabar", "intrabar", []step{
+			{closed: 50, send: true, want: true},
+			{closed: 50, send: true, want: true},
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			b, sender := newTestBot(t, `{"telegram_token": "x", "bar_mode": "`+c.barMode+`"}`)
+			sent := 0
+			for i, s := range c.steps {
+				// Сбрасываем дедупликацию нотификатора: проверяется только учёт свечей ботом.
+				b.notifier.ClearSignalState(1, "BTCUSDT", "60")
+				if got := b.ProcessCandles("BTCUSDT", "60", testCandles(s.closed), s.send); got != s.want {
+					t.Fatalf("step %d: ProcessCandles(closed=%d, send=%v) = %v, want %v", i, s.closed, s.send, got, s.want)
+				}
+				if s.want {
+					sent++
+				}
+			}
+			b.notifier.Close()
+			if got := int(sender.sent.Load()); got != sent {
+				t.Fatalf("sent %d messages, want %d", got, sent)
+			}
+		})
+	}
+}
+
+func TestTimeframesMergesSinkTimeframes(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		subs    map[int64][]string
+		sinkTFs []string
+		want    []string
+	}{
+		{"subscribers only", map[int64][]string{1: {"60"}, 2: {"60", "240"}}, nil, []string{"240", "60"}},
+		{"sinks add timeframes", map[int64][]string{1: {"60"}}, []string{"240", "D", "60"}, []string{"240", "60", "D"}},
+		{"sinks without subscribers", nil, []string{"15", "15"}, []string{"15"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			b, _ := newTestBot(t, `{"telegram_token": "x"}`)
+			if _, err := b.subs.Unsubscribe(1); err != nil {
+				t.Fatal(err)
+			}
+			for chatID, tfs := range c.subs {
+				if _, err := b.subs.Subscribe(chatID); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := b.subs.Update(chatID, func(p *subscribers.Profile) { p.Timeframes = tfs }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b.sinkTFs = c.sinkTFs
+			got := b.Timeframes()
+			slices.Sort(got)
+			if !slices.Equal(got, c.want) {
+				t.Fatalf("Timeframes() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestProcessCandlesMinBars(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		symbol string
+		closed int
+		want   bool
+	}{
+		{"enough history", "ETHUSDT", 60, true},
+		{"short history", "ETHUSDT", 59, false},
+		{"included symbol", "NEWUSDT", 40, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			b, _ := newTestBot(t, `{"telegram_token": "x", "bar_mode": "closed", "universe": {"min_bars": 60, "include": ["NEWUSDT"]}}`)
+			b.ProcessCandles(c.symbol, "60", testCandles(c.closed), false)
+			ranked := slices.ContainsFunc(b.board.Ranking("60", time.Now()), func(e leaderboard.Entry) bool { return e.Symbol == c.symbol })
+			if ranked != c.want {
+				t.Fatalf("symbol counted for /top = %v, want %v", ranked, c.want)
+			}
+		})
+	}
+}
+
+func TestProcessCandlesStreamsIndicators(t *testing.T) {
+	b, _ := newTestBot(t, `{"telegram_token": "x", "data_source": "stream"}`)
+	moved := testCandles(59)
+	moved[len(moved)-1].Close += 2
+	var calc *rsi.StochRSI
+	for _, c := range []struct {
+		name    string
+		candles []exchange.Candle
+		reuse   bool
+	}{
+		{"first update seeds the series", testCandles(59), false},
+		{"same bar updates the price", moved, true},
+		{"new bar fixes the closed one", testCandles(60), true},
+		{"gap reseeds the series", testCandles(63), false},
+	} {
+		b.ProcessCandles("BTCUSDT", "60", c.candles, false)
+		st := b.indicators["BTCUSDT|60"]
+		if reused := st.calc == calc; reused != c.reuse {
+			t.Fatalf("%s: calculator reused = %v, want %v", c.name, reused, c.reuse)
+		}
+		calc = st.calc
+		// Ряд всегда начинается с той же свечи, поэтому потоковые значения совпадают с пересчётом.
+		want := rsi.CalcStochRSI(exchange.Closes(c.candles), 14, 14, 3, 3)
+		if got := b.board.Ranking("60", time.Now())[0].Values; got != want {
+			t.Fatalf("%s: values = %+v, want %+v", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package candlecache хранит свечи по каждой паре (символ, таймфрейм) между циклами сканера:
+// после первой загрузки с биржи запрашиваются только последние несколько свечей,
+// а при разрыве в данных ряд загружается заново.
+package candlecache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/fileutil"
+)
+
+const (
+	// overlapBars — сколько уже известных свечей захватывает догрузка: последняя из них
+	// могла быть незакрытой и с тех пор измениться.
+	overlapBars = 1
+	// extraBars — запас догрузки на случай, если часы биржи и наши немного расходятся.
+	extraBars = 1
+	// saveInterval — как часто Persist записывает кэш на диск.
+	saveInterval = 10 * time.Minute
+	// staleAfter — через сколько без обращений пара удаляется из кэша (пропала из листинга или фильтров).
+	staleAfter = 24 * time.Hour
+)
+
+// Source — откуда кэш берёт свечи; подходит любой exchange.Exchange.
+type Source interface {
+	Candles(symbol, timeframe string, limit int) ([]exchange.Candle, error)
+}
+
+// Stats — счётчики запросов к бирже с момента создания кэша.
+type Stats struct {
+	Full        int // загрузки ряда целиком
+	Incremental int // догрузки последних свечей
+	Gaps        int // догрузки, которые не сошлись с кэшем и потребовали полной загрузки
+}
+
+type series struct {
+	mu       sync.Mutex
+	Bars     []exchange.Candle `json:"bars"`
+	Complete bool              `json:"complete"` // биржа отдала меньше запрошенного: это вся история пары
+	used     time.Time
+}
+
+// Cache — свечи одной биржи.
+type Cache struct {
+	src Source
+
+	mu      sync.Mutex // порядок блокировок: mu, затем mu ряда
+	series  map[string]*series
+	path    string
+	savedAt time.Time
+
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// New создаёт пустой кэш поверх источника свечей.
+func New(src Source) *Cache {
+	return &Cache{src: src, series: make(map[string]*series)}
+}
+
+func key(symbol, timeframe string) string {
+	return symbol + "|" + timeframe
+}
+
+// Candles возвращает последние limit свечей пары в хронологическом порядке, как exchange.Exchange.
+// Пары разных символов загружаются параллельно, одна и та же пара — по очереди.
+func (c *Cache) Candles(symbol, timeframe string, limit int) ([]exchange.Candle, error) {
+	c.mu.Lock()
+	s, ok := c.series[key(symbol, timeframe)]
+	if !ok {
+		s = &series{}
+		c.series[key(symbol, timeframe)] = s
+	}
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.used = now
+	if n := incrementalBars(s, timeframe, limit, now); n > 0 {
+		fresh, err := c.src.Candles(symbol, timeframe, n)
+		if err != nil {
+			return nil, err
+		}
+		if merged, ok := merge(s.Bars, fresh, timeframe); ok {
+			c.count(func(st *Stats) { st.Incremental++ })
+			s.Bars = trim(merged, limit)
+			return clone(s.Bars, limit), nil
+		}
+		c.count(func(st *Stats) { st.Gaps++ })
+	}
+
+	bars, err := c.src.Candles(symbol, timeframe, limit)
+	if err != nil {
+		return nil, err
+	}
+	c.count(func(st *Stats) { st.Full++ })
+	s.Bars = bars
+	s.Complete = len(bars) < limit
+	return clone(s.Bars, limit), nil
+}
+
+// incrementalBars решает, сколько свечей догрузить; 0 — ряд нужно загрузить целиком.
+func incrementalBars(s *series, timeframe string, limit int, now time.Time) int {
+	d := exchange.TimeframeDuration(timeframe)
+	if d <= 0 || len(s.Bars) == 0 || (len(s.Bars) < limit && !s.Complete) {
+		return 0
+	}
+	last := s.Bars[len(s.Bars)-1].OpenTime
+	n := int(now.Sub(last)/d) + overlapBars + extraBars
+	// Если пропущено больше половины ряда, дешевле загрузить его заново.
+	if n > limit/2 {
+		return 0
+	}
+	return n
+}
+
+// merge дописывает свежие свечи к кэшу. Свежий ряд должен начинаться с уже известной свечи
+// и идти без пропусков; иначе возвращает false, и ряд загружается заново.
+func merge(cached, fresh []exchange.Candle, timeframe string) ([]exchange.Candle, bool) {
+	if len(fresh) == 0 {
+		return nil, false
+	}
+	d := exchange.TimeframeDuration(timeframe)
+	for i := 1; i < len(fresh); i++ {
+		if fresh[i].OpenTime.Sub(fresh[i-1].OpenTime) != d {
+			return nil, false
+		}
+	}
+	first := fresh[0].OpenTime
+	i := sort.Search(len(cached), func(i int) bool { return !cached[i].OpenTime.Before(first) })
+	if i == len(cached) || !cached[i].OpenTime.Equal(first) {
+		return nil, false
+	}
+	merged := make([]exchange.Candle, 0, i+len(fresh))
+	merged = append(merged, cached[:i]...)
+	return append(merged, fresh...), true
+}
+
+func trim(bars []exchange.Candle, limit int) []exchange.Candle {
+	if len(bars) > limit {
+		return bars[len(bars)-limit:]
+	}
+	return bars
+}
+
+// clone возвращает копию последних limit свечей: получатели могут держать ряд дольше цикла.
+func clone(bars []exchange.Candle, limit int) []exchange.Candle {
+	return append([]exchange.Candle(nil), trim(bars, limit)...)
+}
+
+func (c *Cache) count(f func(*Stats)) {
+	c.statsMu.Lock()
+	f(&c.stats)
+	c.statsMu.Unlock()
+}
+
+// Stats возвращает счётчики запросов.
+func (c *Cache) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// Prune удаляет пары, к которым не обращались дольше staleAfter.
+func (c *Cache) Prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, s := range c.series {
+		s.mu.Lock()
+		stale := !s.used.IsZero() && now.Sub(s.used) > staleAfter
+		s.mu.Unlock()
+		if stale {
+			delete(c.series, k)
+		}
+	}
+}
+
+// Load включает сохранение кэша в файл path и загружает его, чтобы после перезапуска
+// не скачивать заново всю историю. Устаревшие ряды догрузятся или перезагрузятся сами.
+func (c *Cache) Load(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path = path
+	c.savedAt = time.Now()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var saved map[string]*series
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	now := time.Now()
+	for k, s := range saved {
+		if s != nil && len(s.Bars) > 0 {
+			s.used = now
+			c.series[k] = s
+		}
+	}
+	return nil
+}
+
+// Save записывает кэш в файл, заданный Load; без Load ничего не делает.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" {
+		return nil
+	}
+	snapshot := make(map[string]*series, len(c.series))
+	for k, s := range c.series {
+		s.mu.Lock()
+		if len(s.Bars) > 0 {
+			snapshot[k] = &series{Bars: append([]exchange.Candle(nil), s.Bars...), Complete: s.Complete}
+		}
+		s.mu.Unlock()
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(c.path, data, 0644); err != nil {
+		return err
+	}
+	c.savedAt = time.Now()
+	return nil
+}
+
+// Persist записывает кэш, если с прошлой записи прошло больше saveInterval.
+func (c *Cache) Persist() error {
+	c.mu.Lock()
+	due := c.path != "" && time.Since(c.savedAt) >= saveInterval
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return c.Save()
+}
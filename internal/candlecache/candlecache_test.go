@@ -0,0 +1,119 @@
+package candlecache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+)
+
+// fakeSource отдаёт минутные свечи, последняя из которых — текущая; история пары — history свечей.
+type fakeSource struct {
+	history int
+	skip    time.Time // эта свеча пропадает из ответа, как при сбое биржи
+	limits  []int
+}
+
+func (f *fakeSource) Candles(symbol, timeframe string, limit int) ([]exchange.Candle, error) {
+	f.limits = append(f.limits, limit)
+	d := exchange.TimeframeDuration(timeframe)
+	last := time.Now().Truncate(d)
+	n := min(limit, f.history)
+	var out []exchange.Candle
+	for i := n - 1; i >= 0; i-- {
+		open := last.Add(-time.Duration(i) * d)
+		if open.Equal(f.skip) {
+			continue
+		}
+		out = append(out, exchange.Candle{OpenTime: open, Close: float64(open.Unix() / 60), Confirmed: i > 0})
+	}
+	return out, nil
+}
+
+func TestCacheFetchesOnlyNewBars(t *testing.T) {
+	src := &fakeSource{history: 1000}
+	c := New(src)
+
+	first, err := c.Candles("BTCUSDT", "1", 100)
+	if err != nil || len(first) != 100 {
+		t.Fatalf("first Candles() = %d bars, %v", len(first), err)
+	}
+	second, err := c.Candles("BTCUSDT", "1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, _ := src.Candles("BTCUSDT", "1", 100)
+	if !reflect.DeepEqual(second, full) {
+		t.Fatalf("cached series differs from a full fetch:\n%v\n%v", second, full)
+	}
+	if src.limits[0] != 100 || src.limits[1] > 3 {
+		t.Fatalf("requested limits %v, want a full load and then a few bars", src.limits[:2])
+	}
+	if st := c.Stats(); st.Full != 1 || st.Incremental != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestCacheReloadsOnGap(t *testing.T) {
+	src := &fakeSource{history: 1000}
+	c := New(src)
+	if _, err := c.Candles("BTCUSDT", "1", 100); err != nil {
+		t.Fatal(err)
+	}
+	// Кэш отстал на пять свечей, а в догрузке биржа пропустила одну из них.
+	behind, _ := src.Candles("BTCUSDT", "1", 105)
+	c.series[key("BTCUSDT", "1")].Bars = behind[:100]
+	src.skip = time.Now().Truncate(time.Minute).Add(-3 * time.Minute)
+
+	got, err := c.Candles("BTCUSDT", "1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Stats(); st.Gaps != 1 || st.Full != 2 {
+		t.Fatalf("stats = %+v, want the gap to trigger a full reload", st)
+	}
+	if len(got) != 99 {
+		t.Fatalf("got %d bars after reload, want the exchange's 99", len(got))
+	}
+}
+
+func TestCacheKeepsShortHistory(t *testing.T) {
+	src := &fakeSource{history: 40}
+	c := New(src)
+	for range 2 {
+		if bars, err := c.Candles("NEWUSDT", "1", 100); err != nil || len(bars) != 40 {
+			t.Fatalf("Candles() = %d bars, %v, want the whole 40-bar history", len(bars), err)
+		}
+	}
+	if st := c.Stats(); st.Full != 1 || st.Incremental != 1 {
+		t.Fatalf("stats = %+v, short history was reloaded instead of extended", st)
+	}
+}
+
+func TestCacheSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candles.bybit.json")
+	src := &fakeSource{history: 1000}
+	c := New(src)
+	if err := c.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Candles("BTCUSDT", "1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := New(src)
+	if err := restarted.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Candles("BTCUSDT", "1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if st := restarted.Stats(); st.Full != 0 || st.Incremental != 1 {
+		t.Fatalf("stats after restart = %+v, want only new bars fetched", st)
+	}
+}
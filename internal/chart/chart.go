@@ -0,0 +1,253 @@
+// Package chart рисует PNG-график сигнала без внешних сервисов: свечи цены,
+// RSI с уровнями 70/30 и Stoch RSI %K/%D с уровнями 80/20.
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+// Options — параметры графика.
+type Options struct {
+	Title       string // подпись в левом верхнем углу, например "BTCUSDT 1h"
+	Bars        int    // сколько последних свечей показать
+	RSIPeriod   int
+	StochPeriod int
+	SmoothK     int
+	SmoothD     int
+}
+
+const (
+	width   = 800
+	height  = 600
+	padLeft = 10
+	// padRight — место под подписи шкалы справа.
+	padRight = 70
+)
+
+var (
+	colorBackground = color.RGBA{0x13, 0x17, 0x22, 0xff}
+	colorGrid       = color.RGBA{0x2a, 0x2e, 0x39, 0xff}
+	colorText       = color.RGBA{0xd1, 0xd4, 0xdc, 0xff}
+	colorUp         = color.RGBA{0x26, 0xa6, 0x9a, 0xff}
+	colorDown       = color.RGBA{0xef, 0x53, 0x50, 0xff}
+	colorRSI        = color.RGBA{0xb3, 0x88, 0xff, 0xff}
+	colorK          = color.RGBA{0x29, 0x62, 0xff, 0xff}
+	colorD          = color.RGBA{0xff, 0x98, 0x00, 0xff}
+	colorBand       = color.RGBA{0x78, 0x7b, 0x86, 0xff}
+)
+
+// panel — горизонтальная полоса графика со своей шкалой по вертикали.
+type panel struct {
+	top, bottom int
+	min, max    float64
+}
+
+func (p panel) y(v float64) int {
+	if p.max == p.min {
+		return (p.top + p.bottom) / 2
+	}
+	return p.bottom - int(math.Round((v-p.min)/(p.max-p.min)*float64(p.bottom-p.top)))
+}
+
+// Render рисует последние opts.Bars свечей и индикаторы под ними и пишет PNG в w.
+// Индикаторы считаются по всей переданной истории, поэтому свечей должно быть
+// больше, чем opts.Bars, на прогрев RSI и Stoch RSI.
+func Render(w io.Writer, candles []exchange.Candle, opts Options) error {
+	if len(candles) == 0 {
+		return errors.New("нет свечей для графика")
+	}
+	bars := opts.Bars
+	if bars <= 0 || bars > len(candles) {
+		bars = len(candles)
+	}
+	shown := candles[len(candles)-bars:]
+	values := series(exchange.Closes(candles), bars, opts)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+
+	price := panel{top: 30, bottom: 340, min: shown[0].Low, max: shown[0].High}
+	for _, c := range shown {
+		price.min = math.Min(price.min, c.Low)
+		price.max = math.Max(price.max, c.High)
+	}
+	rsiPanel := panel{top: 360, bottom: 470, min: 0, max: 100}
+	stochPanel := panel{top: 485, bottom: 590, min: 0, max: 100}
+
+	step := float64(width-padLeft-padRight) / float64(bars)
+	x := func(i int) int { return padLeft + int(step*(float64(i)+0.5)) }
+
+	// Сетка и шкала цены.
+	for i := 0; i <= 4; i++ {
+		v := price.min + (price.max-price.min)*float64(i)/4
+		y := price.y(v)
+		hline(img, padLeft, width-padRight, y, colorGrid)
+		label(img, width-padRight+5, y+4, formatPrice(v), colorText)
+	}
+	for _, p := range []struct {
+		panel panel
+		bands []float64
+	}{{rsiPanel, []float64{30, 70}}, {stochPanel, []float64{20, 80}}} {
+		hline(img, padLeft, width-padRight, p.panel.top, colorGrid)
+		hline(img, padLeft, width-padRight, p.panel.bottom, colorGrid)
+		for _, b := range p.bands {
+			dashed(img, padLeft, width-padRight, p.panel.y(b), colorBand)
+			label(img, width-padRight+5, p.panel.y(b)+4, strconv.Itoa(int(b)), colorBand)
+		}
+	}
+
+	// Свечи: тень на всю высоту и тело не уже 1 пикселя.
+	body := max(1, int(step*0.6))
+	for i, c := range shown {
+		col := colorUp
+		if c.Close < c.Open {
+			col = colorDown
+		}
+		cx := x(i)
+		vline(img, cx, price.y(c.High), price.y(c.Low), col)
+		top, bottom := price.y(math.Max(c.Open, c.Close)), price.y(math.Min(c.Open, c.Close))
+		fill(img, image.Rect(cx-body/2, top, cx-body/2+body, bottom+1), col)
+	}
+
+	plot(img, rsiPanel, values.rsi, x, colorRSI)
+	plot(img, stochPanel, values.k, x, colorK)
+	plot(img, stochPanel, values.d, x, colorD)
+
+	last := shown[len(shown)-1]
+	label(img, padLeft, 18, fmt.Sprintf("%s  O %s  H %s  L %s  C %s", opts.Title,
+		formatPrice(last.Open), formatPrice(last.High), formatPrice(last.Low), formatPrice(last.Close)), colorText)
+	label(img, padLeft+4, rsiPanel.top+14, fmt.Sprintf("RSI(%d) %.2f", opts.RSIPeriod, lastValue(values.rsi)), colorRSI)
+	label(img, padLeft+4, stochPanel.top+14, fmt.Sprintf("Stoch RSI(%d,%d,%d) K %.2f", opts.StochPeriod, opts.SmoothK, opts.SmoothD, lastValue(values.k)), colorK)
+	label(img, padLeft+220, stochPanel.top+14, fmt.Sprintf("D %.2f", lastValue(values.d)), colorD)
+
+	return png.Encode(w, img)
+}
+
+// indicatorSeries — значения индикаторов на показанных свечах; NaN — не хватило истории.
+type indicatorSeries struct {
+	rsi, k, d []float64
+}
+
+// series берёт значения индикаторов на последних bars свечах из полного ряда по всем ценам.
+func series(closes []float64, bars int, opts Options) indicatorSeries {
+	full := rsi.StochRSISeries(closes, opts.RSIPeriod, opts.StochPeriod, opts.SmoothK, opts.SmoothD)
+	from := len(closes) - bars
+	return indicatorSeries{rsi: full.RSI[from:], k: full.K[from:], d: full.D[from:]}
+}
+
+func lastValue(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	// Погрешность SMA у нуля не должна печататься как -0.00.
+	if v := values[len(values)-1]; math.Abs(v) >= 0.005 {
+		return v
+	}
+	return 0
+}
+
+func plot(img *image.RGBA, p panel, values []float64, x func(int) int, col color.Color) {
+	for i := 1; i < len(values); i++ {
+		if math.IsNaN(values[i-1]) || math.IsNaN(values[i]) {
+			continue
+		}
+		line(img, x(i-1), p.y(values[i-1]), x(i), p.y(values[i]), col)
+	}
+}
+
+// line рисует отрезок алгоритмом Брезенхэма.
+func line(img *image.RGBA, x0, y0, x1, y1 int, col color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	errAcc := dx + dy
+	for {
+		img.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * errAcc; e2 >= dy {
+			errAcc += dy
+			x0 += sx
+		} else {
+			errAcc += dx
+			y0 += sy
+		}
+	}
+}
+
+func hline(img *image.RGBA, x0, x1, y int, col color.Color) {
+	for x := x0; x <= x1; x++ {
+		img.Set(x, y, col)
+	}
+}
+
+func dashed(img *image.RGBA, x0, x1, y int, col color.Color) {
+	for x := x0; x <= x1; x++ {
+		if (x/4)%2 == 0 {
+			img.Set(x, y, col)
+		}
+	}
+}
+
+func vline(img *image.RGBA, x, y0, y1 int, col color.Color) {
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	for y := y0; y <= y1; y++ {
+		img.Set(x, y, col)
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, col color.Color) {
+	draw.Draw(img, r, &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+func label(img *image.RGBA, x, y int, text string, col color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// formatPrice печатает цену с точностью, заметной для монет любого масштаба.
+func formatPrice(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1000:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case a >= 1:
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	default:
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
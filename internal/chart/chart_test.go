@@ -0,0 +1,65 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+)
+
+func TestRenderDrawsAllPanels(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []exchange.Candle
+	for i := 0; i < 120; i++ {
+		c := 100 + 10*math.Sin(float64(i)/6)
+		candles = append(candles, exchange.Candle{
+			OpenTime: start.Add(time.Duration(i) * time.Hour),
+			Open:     c - 1, High: c + 2, Low: c - 3, Close: c,
+		})
+	}
+
+	var buf bytes.Buffer
+	opts := Options{Title: "BTCUSDT 1h", Bars: 60, RSIPeriod: 14, StochPeriod: 14, SmoothK: 3, SmoothD: 3}
+	if err := Render(&buf, candles, opts); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() error: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Fatalf("image size = %v, want %dx%d", b, width, height)
+	}
+
+	// В каждой панели, кроме фона и сетки, должны быть пиксели линий.
+	for _, p := range []struct {
+		name        string
+		top, bottom int
+		col         [3]uint8
+	}{
+		{"price", 30, 340, [3]uint8{colorUp.R, colorUp.G, colorUp.B}},
+		{"rsi", 360, 470, [3]uint8{colorRSI.R, colorRSI.G, colorRSI.B}},
+		{"stoch", 485, 590, [3]uint8{colorK.R, colorK.G, colorK.B}},
+	} {
+		found := false
+		for y := p.top; y <= p.bottom && !found; y++ {
+			for x := padLeft; x < width-padRight; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				if uint8(r>>8) == p.col[0] && uint8(g>>8) == p.col[1] && uint8(b>>8) == p.col[2] {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s panel has no plotted pixels", p.name)
+		}
+	}
+
+	if err := Render(&buf, nil, opts); err == nil {
+		t.Fatal("Render() without candles should fail")
+	}
+}
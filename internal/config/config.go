@@ -1,43 +1,286 @@
-// Package config хранит настройки бота (токен, таймфрейм и лимиты).
+// Package config хранит настройки бота (токен, таймфрейм, параметры индикаторов и лимиты).
 package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+
+	"grevtsevalex/crypto-bot/internal/rules"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+	"grevtsevalex/crypto-bot/internal/universe"
 )
 
 // Config — параметры бота.
 type Config struct {
 	TelegramToken      string `json:"telegram_token"`
 	SubscribersFile    string `json:"subscribers_file"`
-	SignalMode         string `json:"signal_mode"`
-	Timeframe          string `json:"timeframe"`
+	Exchange           string `json:"exchange"`    // биржа рыночных данных: bybit, binance или okx
+	DataSource         string `json:"data_source"` // poll — опрос REST раз в минуту, stream — WebSocket (только bybit)
+	BarMode            string `json:"bar_mode"`    // closed — только закрытые свечи, intrabar — с учётом текущей свечи
+	SignalMode         string `json:"signal_mode"` // режим по умолчанию для новых подписчиков: upper, lower или both
+	Timeframe          string `json:"timeframe"`   // таймфрейм по умолчанию для новых подписчиков
 	LockTimeframe      bool   `json:"lock_timeframe"`
-	MaxSignalsPerCycle int    `json:"max_signals_per_cycle"` // макс. уведомлений за один проход по парам
+	MaxSignalsPerCycle int    `json:"max_signals_per_cycle"` // макс. уведомлений за цикл сканера
 	CandleLimit        int    `json:"candle_limit"`          // число часовых свечей для расчёта
+	Chart              bool   `json:"chart"`                 // прикладывать к сигналу PNG-график свечей, RSI и Stoch RSI
+	ChartBars          int    `json:"chart_bars"`            // сколько последних свечей показывать на графике
+
+	// Файлы бота; пустые имена выводятся из subscribers_file.
+	HistoryFile      string `json:"history_file"`        // журнал разосланных сигналов JSONL
+	HistoryMaxSizeMB int    `json:"history_max_size_mb"` // размер журнала, после которого он ротируется
+	// Состояние дедупликации сигналов переживает перезапуск; запись устаревает,
+	// если символ не подтверждал зону дольше signal_state_expiry_bars свечей.
+	SignalStateFile       string `json:"signal_state_file"`
+	SignalStateExpiryBars int    `json:"signal_state_expiry_bars"`
+	// После сигнала бот следит за ценой на горизонтах outcome_horizons (в свечах) для /stats.
+	OutcomeHorizons []int  `json:"outcome_horizons"`
+	OutcomesFile    string `json:"outcomes_file"`
+
+	RSIPeriod       int     `json:"rsi_period"`        // период RSI по Уайлдеру
+	StochPeriod     int     `json:"stoch_period"`      // окно min/max RSI для Stoch RSI
+	SmoothK         int     `json:"smooth_k"`          // %K = SMA(raw, smooth_k)
+	SmoothD         int     `json:"smooth_d"`          // %D = SMA(%K, smooth_d)
+	RSIUpper        float64 `json:"rsi_upper"`         // порог RSI верхней зоны по умолчанию для подписчиков
+	RSILower        float64 `json:"rsi_lower"`         // порог RSI нижней зоны по умолчанию для подписчиков
+	StochUpper      float64 `json:"stoch_upper"`       // порог %K верхней зоны по умолчанию для подписчиков
+	StochLower      float64 `json:"stoch_lower"`       // порог %K нижней зоны по умолчанию для подписчиков
+	StochLowerSlack float64 `json:"stoch_lower_slack"` // допуск %K над нижним порогом (сглаженная линия редко доходит до нуля)
+
+	// Sinks — дополнительные каналы доставки: все сигналы бота по его порогам по умолчанию
+	// уходят в них вместе с рассылкой подписчикам.
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+
+	// Universe ограничивает пары, которые сканирует бот: неликвидные и только что
+	// залистенные пары с короткой историей дают бесполезные сигналы.
+	Universe universe.Filter `json:"universe"`
+
+	// SignalRules заменяет пороговое правило режима (upper или lower) деревом условий из пакета rules.
+	// Для режима с правилом пороги профилей подписчиков не используются.
+	SignalRules map[string]*rules.Rule `json:"signal_rules,omitempty"`
 }
 
-var (
-	cfg     Config
-	cfgMu   sync.RWMutex
-	cfgPath string
+// Типы каналов доставки.
+const (
+	SinkTelegram = "telegram"
+	SinkDiscord  = "discord"
+	SinkSlack    = "slack"
+	SinkWebhook  = "webhook"
+	SinkEmail    = "email"
 )
 
+// SinkConfig — канал доставки сигналов помимо подписчиков бота.
+type SinkConfig struct {
+	Type       string   `json:"type"`                 // telegram, discord, slack, webhook или email
+	Name       string   `json:"name,omitempty"`       // имя для логов и журнала; по умолчанию — тип
+	Mode       string   `json:"mode,omitempty"`       // upper, lower или both; по умолчанию signal_mode
+	Timeframes []string `json:"timeframes,omitempty"` // по умолчанию timeframe бота
+
+	ChatID  int64             `json:"chat_id,omitempty"` // telegram: канал или группа
+	URL     string            `json:"url,omitempty"`     // discord, slack, webhook
+	Headers map[string]string `json:"headers,omitempty"` // webhook: дополнительные заголовки, например авторизация
+
+	SMTPHost string   `json:"smtp_host,omitempty"` // email
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Validate проверяет, что для типа канала заданы обязательные поля.
+func (s SinkConfig) Validate() error {
+	switch s.Mode {
+	case "", "upper", "lower", "both":
+	default:
+		return fmt.Errorf("неизвестный режим %q", s.Mode)
+	}
+	for _, tf := range s.Timeframes {
+		if !ValidTimeframe(tf) {
+			return fmt.Errorf("неподдерживаемый таймфрейм %q", tf)
+		}
+	}
+	switch s.Type {
+	case SinkTelegram:
+		if s.ChatID == 0 {
+			return errors.New("telegram: нужен chat_id")
+		}
+	case SinkDiscord, SinkSlack, SinkWebhook:
+		if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+			return fmt.Errorf("%s: нужен url http(s)", s.Type)
+		}
+	case SinkEmail:
+		if s.SMTPHost == "" || s.From == "" || len(s.To) == 0 {
+			return errors.New("email: нужны smtp_host, from и to")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип канала %q", s.Type)
+	}
+	return nil
+}
+
+// MaxOutcomeHorizon — самый дальний горизонт отслеживания сигнала: свечи от сигнала
+// до горизонта должны помещаться в один запрос к любой из бирж.
+const MaxOutcomeHorizon = 200
+
+// okxMaxCandleLimit — сколько свечей OKX отдаёт за один запрос; больше candle_limit не загрузить.
+const okxMaxCandleLimit = 300
+
+// Timeframes — поддерживаемые таймфреймы свечей Bybit.
+var Timeframes = []string{"1", "5", "15", "60", "240", "D"}
+
+// ValidTimeframe сообщает, поддерживается ли таймфрейм.
+func ValidTimeframe(timeframe string) bool {
+	for _, tf := range Timeframes {
+		if tf == timeframe {
+			return true
+		}
+	}
+	return false
+}
+
+// Store — конфиг одного бота, привязанный к своему файлу.
+type Store struct {
+	mu   sync.RWMutex
+	cfg  Config
+	path string
+}
+
 // Default возвращает значения по умолчанию.
 func Default() Config {
 	return Config{
-		SubscribersFile:    "subscribers.json",
-		SignalMode:         "upper",
-		Timeframe:          "60",
-		MaxSignalsPerCycle: 10,
-		CandleLimit:        100,
+		SubscribersFile:       "subscribers.json",
+		HistoryFile:           "signals.jsonl",
+		HistoryMaxSizeMB:      10,
+		SignalStateFile:       "signal_state.json",
+		SignalStateExpiryBars: 3,
+		OutcomeHorizons:       []int{1, 4, 12, 24},
+		OutcomesFile:          "outcomes.json",
+		Chart:                 true,
+		ChartBars:             60,
+		Exchange:              "bybit",
+		DataSource:            "poll",
+		BarMode:               "intrabar",
+		SignalMode:            "upper",
+		Timeframe:             "60",
+		MaxSignalsPerCycle:    10,
+		CandleLimit:           100,
+		RSIPeriod:             14,
+		StochPeriod:           14,
+		SmoothK:               3,
+		SmoothD:               3,
+		RSIUpper:              70,
+		RSILower:              30,
+		StochUpper:            99.99,
+		StochLower:            0,
+		StochLowerSlack:       1,
 	}
 }
 
+// Validate проверяет параметры индикаторов и порогов.
+func (c Config) Validate() error {
+	switch {
+	case c.RSIPeriod < 2 || c.RSIPeriod > 100:
+		return fmt.Errorf("rsi_period должен быть от 2 до 100, получено %d", c.RSIPeriod)
+	case c.StochPeriod < 1 || c.StochPeriod > 100:
+		return fmt.Errorf("stoch_period должен быть от 1 до 100, получено %d", c.StochPeriod)
+	case c.SmoothK < 1 || c.SmoothK > 20:
+		return fmt.Errorf("smooth_k должен быть от 1 до 20, получено %d", c.SmoothK)
+	case c.SmoothD < 1 || c.SmoothD > 20:
+		return fmt.Errorf("smooth_d должен быть от 1 до 20, получено %d", c.SmoothD)
+	case c.RSILower <= 0 || c.RSIUpper >= 100 || c.RSILower >= c.RSIUpper:
+		return fmt.Errorf("нужно 0 < rsi_lower < rsi_upper < 100, получено %.2f и %.2f", c.RSILower, c.RSIUpper)
+	case c.StochLower < 0 || c.StochUpper > 100 || c.StochLower >= c.StochUpper:
+		return fmt.Errorf("нужно 0 ≤ stoch_lower < stoch_upper ≤ 100, получено %.2f и %.2f", c.StochLower, c.StochUpper)
+	case c.StochLowerSlack < 0 || c.StochLower+c.StochLowerSlack >= c.StochUpper:
+		return fmt.Errorf("stoch_lower_slack должен быть ≥ 0 и не доставать до stoch_upper, получено %.2f", c.StochLowerSlack)
+	}
+	for _, h := range c.OutcomeHorizons {
+		if h < 1 || h > MaxOutcomeHorizon {
+			return fmt.Errorf("outcome_horizons: горизонт должен быть от 1 до %d свечей, получено %d", MaxOutcomeHorizon, h)
+		}
+	}
+	sinkNames := make(map[string]bool, len(c.Sinks))
+	for i, sink := range c.Sinks {
+		if err := sink.Validate(); err != nil {
+			return fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		if sinkNames[sink.Name] {
+			return fmt.Errorf("sinks[%d]: имя %q уже занято", i, sink.Name)
+		}
+		sinkNames[sink.Name] = true
+	}
+	if err := c.Universe.Validate(); err != nil {
+		return fmt.Errorf("universe: %w", err)
+	}
+	if c.Universe.NeedsMarketInfo() && c.Exchange != "" && c.Exchange != "bybit" {
+		return fmt.Errorf("universe: min_turnover_24h и min_listing_days поддерживаются только для bybit, а не %s", c.Exchange)
+	}
+	if c.Exchange == "okx" && c.CandleLimit > okxMaxCandleLimit {
+		return fmt.Errorf("candle_limit=%d: OKX отдаёт не больше %d свечей за запрос", c.CandleLimit, okxMaxCandleLimit)
+	}
+	if c.Universe.MinBars > c.CandleLimit {
+		return fmt.Errorf("universe: min_bars=%d больше candle_limit=%d", c.Universe.MinBars, c.CandleLimit)
+	}
+	for mode, rule := range c.SignalRules {
+		if mode != "upper" && mode != "lower" {
+			return fmt.Errorf("signal_rules: неизвестный режим %q (допустимы upper и lower)", mode)
+		}
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("signal_rules.%s: %w", mode, err)
+		}
+	}
+	if warmup := c.WarmupBars(); c.CandleLimit < warmup {
+		return fmt.Errorf("candle_limit=%d меньше прогрева индикаторов (%d свечей)", c.CandleLimit, warmup)
+	}
+	return nil
+}
+
+// WarmupBars возвращает минимальное число свечей, при котором определены RSI, %K и %D
+// на всех барах, к которым обращаются правила сигналов.
+func (c Config) WarmupBars() int {
+	lookback := 0
+	for _, rule := range c.SignalRules {
+		if rule != nil && rule.Lookback() > lookback {
+			lookback = rule.Lookback()
+		}
+	}
+	return c.RSIPeriod + c.StochPeriod + c.SmoothK + c.SmoothD + lookback
+}
+
+// DefaultProfile возвращает профиль новых подписчиков по настройкам конфига.
+func (c Config) DefaultProfile() subscribers.Profile {
+	return subscribers.Profile{
+		Mode:       c.SignalMode,
+		Timeframes: []string{c.Timeframe},
+		RSIUpper:   c.RSIUpper,
+		RSILower:   c.RSILower,
+		StochUpper: c.StochUpper,
+		StochLower: c.StochLower,
+	}
+}
+
+// SinkProfile возвращает профиль, по которому канал получает сигналы: пороги по умолчанию
+// с режимом и таймфреймами канала, если они заданы.
+func (c Config) SinkProfile(s SinkConfig) subscribers.Profile {
+	p := c.DefaultProfile()
+	if s.Mode != "" {
+		p.Mode = s.Mode
+	}
+	if len(s.Timeframes) > 0 {
+		p.Timeframes = append([]string(nil), s.Timeframes...)
+	}
+	return p
+}
+
 func normalize(c *Config) {
 	switch c.SignalMode {
-	case "upper", "lower":
+	case "upper", "lower", "both":
 	default:
 		c.SignalMode = "upper"
 	}
@@ -48,9 +291,45 @@ func normalize(c *Config) {
 			c.SubscribersFile = "subscribers.json"
 		}
 	}
-	switch c.Timeframe {
-	case "1", "5", "15", "60", "240", "D":
+	if c.HistoryFile == "" {
+		c.HistoryFile = siblingFile(c.SubscribersFile, "signals", ".jsonl")
+	}
+	if c.HistoryMaxSizeMB <= 0 {
+		c.HistoryMaxSizeMB = 10
+	}
+	if c.SignalStateFile == "" {
+		c.SignalStateFile = siblingFile(c.SubscribersFile, "signal_state", ".json")
+	}
+	if c.SignalStateExpiryBars <= 0 {
+		c.SignalStateExpiryBars = 3
+	}
+	if len(c.OutcomeHorizons) == 0 {
+		c.OutcomeHorizons = []int{1, 4, 12, 24}
+	}
+	for i := range c.Sinks {
+		if c.Sinks[i].Name == "" {
+			c.Sinks[i].Name = fmt.Sprintf("%s-%d", c.Sinks[i].Type, i+1)
+		}
+	}
+	c.Universe.Normalize()
+	if c.ChartBars < 10 || c.ChartBars > 200 {
+		c.ChartBars = 60
+	}
+	if c.OutcomesFile == "" {
+		c.OutcomesFile = siblingFile(c.SubscribersFile, "outcomes", ".json")
+	}
+	switch c.Exchange {
+	case "bybit", "binance", "okx":
 	default:
+		c.Exchange = "bybit"
+	}
+	if c.DataSource != "stream" || c.Exchange != "bybit" {
+		c.DataSource = "poll"
+	}
+	if c.BarMode != "closed" {
+		c.BarMode = "intrabar"
+	}
+	if !ValidTimeframe(c.Timeframe) {
 		c.Timeframe = "60"
 	}
 	if c.MaxSignalsPerCycle <= 0 {
@@ -61,53 +340,124 @@ func normalize(c *Config) {
 	}
 }
 
+// siblingFile выводит имя файла бота из имени файла подписчиков, чтобы у ботов
+// с разными подписчиками не пересекались и остальные файлы:
+// subscribers.upper.15.json → signals.upper.15.jsonl.
+func siblingFile(subscribersFile, name, ext string) string {
+	dir, base := filepath.Split(subscribersFile)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if rest, ok := strings.CutPrefix(base, "subscribers"); ok {
+		base = name + rest
+	} else {
+		base += "." + name
+	}
+	return dir + base + ext
+}
+
 // Load загружает конфиг из файла; при отсутствии создаёт с дефолтами.
-func Load(path string) error {
-	cfgPath = path
+func Load(path string) (*Store, error) {
+	s := &Store{path: path}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			cfg = Default()
-			return Save()
+			s.cfg = Default()
+			return s, s.Save()
 		}
-		return err
+		return nil, err
 	}
-	cfgMu.Lock()
-	defer cfgMu.Unlock()
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return err
+	// Отсутствующие поля получают значения по умолчанию; файл подписчиков выводится из режима,
+	// а журнал, состояние и результаты сигналов — из файла подписчиков в normalize.
+	s.cfg = Default()
+	s.cfg.SubscribersFile = ""
+	s.cfg.HistoryFile = ""
+	s.cfg.SignalStateFile = ""
+	s.cfg.OutcomesFile = ""
+	if err := json.Unmarshal(data, &s.cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-	normalize(&cfg)
-	return nil
+	normalize(&s.cfg)
+	if err := s.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// LoadAll загружает несколько конфигов. Каталог в списке раскрывается в файлы config*.json
+// внутри него: рядом обычно лежат подписчики, журналы и состояние ботов в том же формате.
+// Два бота с одним telegram_token в одном процессе запустить нельзя, поэтому дубликаты — ошибка.
+func LoadAll(paths []string) ([]*Store, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(path, "config*.json"))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(matches)
+			files = append(files, matches...)
+			continue
+		}
+		files = append(files, path)
+	}
+
+	stores := make([]*Store, 0, len(files))
+	tokens := make(map[string]string)
+	for _, file := range files {
+		s, err := Load(file)
+		if err != nil {
+			return nil, err
+		}
+		token := s.Get().TelegramToken
+		if token == "" {
+			return nil, fmt.Errorf("укажите telegram_token в %s", file)
+		}
+		if other, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("один telegram_token в %s и %s", other, file)
+		}
+		tokens[token] = file
+		stores = append(stores, s)
+	}
+	return stores, nil
+}
+
+// Path возвращает путь к файлу конфига.
+func (s *Store) Path() string {
+	return s.path
 }
 
 // Save сохраняет конфиг в файл.
-func Save() error {
-	cfgMu.RLock()
-	defer cfgMu.RUnlock()
-	data, err := json.MarshalIndent(cfg, "", "  ")
+func (s *Store) Save() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, err := json.MarshalIndent(s.cfg, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(cfgPath, data, 0644)
+	return os.WriteFile(s.path, data, 0644)
 }
 
 // Get возвращает копию текущего конфига.
-func Get() Config {
-	cfgMu.RLock()
-	defer cfgMu.RUnlock()
-	return cfg
-}
-
-// Update обновляет конфиг и сохраняет в файл.
-func Update(updater func(*Config)) error {
-	cfgMu.Lock()
-	defer cfgMu.Unlock()
-	updater(&cfg)
-	normalize(&cfg)
-	data, err := json.MarshalIndent(cfg, "", "  ")
+func (s *Store) Get() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Update обновляет конфиг и сохраняет в файл. Изменение, не прошедшее Validate, отклоняется.
+func (s *Store) Update(updater func(*Config)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.cfg
+	updater(&next)
+	normalize(&next)
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	s.cfg = next
+	data, err := json.MarshalIndent(s.cfg, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(cfgPath, data, 0644)
+	return os.WriteFile(s.path, data, 0644)
 }
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFillsIndicatorDefaults(t *testing.T) {
+	s, err := Load(writeConfig(t, `{"telegram_token": "x", "signal_mode": "lower", "rsi_period": 7, "stoch_lower_slack": 0}`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	c := s.Get()
+	if c.RSIPeriod != 7 || c.StochPeriod != 14 || c.SmoothK != 3 || c.RSIUpper != 70 {
+		t.Fatalf("Load() = %+v, want rsi_period 7 with other defaults", c)
+	}
+	if c.StochLowerSlack != 0 {
+		t.Fatalf("explicit stoch_lower_slack 0 was overwritten: %v", c.StochLowerSlack)
+	}
+	if c.SubscribersFile != "subscribers.lower.json" {
+		t.Fatalf("SubscribersFile = %q, want lower default", c.SubscribersFile)
+	}
+	if c.HistoryFile != "signals.lower.jsonl" || c.SignalStateFile != "signal_state.lower.json" {
+		t.Fatalf("HistoryFile = %q, SignalStateFile = %q, want them derived from subscribers file", c.HistoryFile, c.SignalStateFile)
+	}
+}
+
+func TestValidateRejectsBadThresholds(t *testing.T) {
+	for name, body := range map[string]string{
+		"rsi order":     `{"rsi_upper": 30, "rsi_lower": 70}`,
+		"stoch order":   `{"stoch_upper": 10, "stoch_lower": 20}`,
+		"period":        `{"rsi_period": 1}`,
+		"short history": `{"rsi_period": 40, "stoch_period": 40, "candle_limit": 60}`,
+		"okx history":   `{"exchange": "okx", "candle_limit": 400}`,
+	} {
+		if _, err := Load(writeConfig(t, body)); err == nil {
+			t.Errorf("%s: Load() accepted invalid config %s", name, body)
+		}
+	}
+}
+
+func TestUpdateKeepsConfigOnInvalidChange(t *testing.T) {
+	s, err := Load(writeConfig(t, `{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(func(c *Config) { c.SmoothK = 0 }); err == nil {
+		t.Fatal("Update() accepted smooth_k = 0")
+	}
+	if s.Get().SmoothK != 3 {
+		t.Fatalf("SmoothK = %d after rejected update, want 3", s.Get().SmoothK)
+	}
+}
+
+func TestLoadAllReadsOnlyConfigFilesFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"config.json":            `{"telegram_token": "a"}`,
+		"config.lower.60.json":   `{"telegram_token": "b", "signal_mode": "lower"}`,
+		"subscribers.json":       `{"123": {"mode": "upper"}}`,
+		"signal_state.json":      `{}`,
+		"candles.bybit.json":     `{}`,
+		"outcomes.lower.60.json": `{"outcomes": []}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stores, err := LoadAll([]string{dir})
+	if err != nil {
+		t.Fatalf("LoadAll() error: %v", err)
+	}
+	if len(stores) != 2 || filepath.Base(stores[0].Path()) != "config.json" || filepath.Base(stores[1].Path()) != "config.lower.60.json" {
+		t.Fatalf("LoadAll() loaded %d configs, want config.json and config.lower.60.json", len(stores))
+	}
+}
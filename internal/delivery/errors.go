@@ -0,0 +1,38 @@
+package delivery
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Причины, по которым чат больше не может получать сообщения бота.
+const (
+	// ReasonBlocked — 403: пользователь заблокировал бота или удалил аккаунт, бота исключили из группы.
+	ReasonBlocked = "blocked"
+	// ReasonChatNotFound — 400: чат удалён или никогда не существовал.
+	ReasonChatNotFound = "chat_not_found"
+	// ReasonMigrated — группа стала супергруппой и получила новый chat_id.
+	ReasonMigrated = "migrated"
+)
+
+// Undeliverable сообщает, что ошибка отправки окончательна для чата: повторять бессмысленно,
+// подписку нужно снять или, для ReasonMigrated, перенести на migrateTo.
+func Undeliverable(err error) (reason string, migrateTo int64, ok bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return "", 0, false
+	}
+	// При загрузке файлов библиотека не заполняет Code, поэтому смотрим и на текст ответа.
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.MigrateToChatID != 0:
+		return ReasonMigrated, apiErr.MigrateToChatID, true
+	case apiErr.Code == 403 || strings.HasPrefix(msg, "forbidden:"):
+		return ReasonBlocked, 0, true
+	case strings.Contains(msg, "chat not found"):
+		return ReasonChatNotFound, 0, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,351 @@
+// Package delivery отправляет сообщения Telegram через очередь с ограничением скорости:
+// общий лимит бота и лимит на чат, пауза по retry_after из ответа 429 и повторы
+// с растущей задержкой при временных ошибках.
+package delivery
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	// ErrBacklogFull — в очереди уже Options.Backlog неотправленных сообщений.
+	ErrBacklogFull = errors.New("очередь отправки переполнена")
+	// ErrStopped — очередь остановлена до отправки сообщения.
+	ErrStopped = errors.New("очередь отправки остановлена")
+)
+
+// Options — лимиты очереди. Нулевые поля заменяются значениями по умолчанию.
+type Options struct {
+	GlobalRate  float64       // сообщений в секунду на бота; Telegram допускает около 30
+	GlobalBurst int           // сколько сообщений можно отправить подряд без паузы
+	ChatRate    float64       // сообщений в секунду в один чат
+	ChatBurst   int           // сколько сообщений подряд в один чат
+	MaxRetries  int           // повторов после первой неудачной попытки
+	BaseBackoff time.Duration // задержка перед первым повтором, дальше удваивается
+	MaxBackoff  time.Duration // верхняя граница задержки повтора
+	Backlog     int           // максимум сообщений, ожидающих отправки
+}
+
+func (o *Options) normalize() {
+	if o.GlobalRate <= 0 {
+		o.GlobalRate = 25
+	}
+	if o.GlobalBurst <= 0 {
+		o.GlobalBurst = 25
+	}
+	if o.ChatRate <= 0 {
+		o.ChatRate = 1
+	}
+	if o.ChatBurst <= 0 {
+		o.ChatBurst = 1
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 5
+	}
+	if o.BaseBackoff <= 0 {
+		o.BaseBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.Backlog <= 0 {
+		o.Backlog = 5000
+	}
+}
+
+// bucket — корзина токенов: rate токенов в секунду, не больше burst.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// readyAt возвращает момент, когда в корзине появится целый токен.
+func (b *bucket) readyAt(now time.Time) time.Time {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return now
+	}
+	return now.Add(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+func (b *bucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+type job struct {
+	chatID    int64
+	msg       tgbotapi.Chattable
+	attempts  int
+	notBefore time.Time
+	done      func(tgbotapi.Message, error)
+}
+
+func (j *job) finish(msg tgbotapi.Message, err error) {
+	if j.done != nil {
+		j.done(msg, err)
+	}
+}
+
+// Queue — очередь исходящих сообщений одного бота.
+type Queue struct {
+	api  *tgbotapi.BotAPI
+	opts Options
+
+	mu          sync.Mutex
+	pending     []*job // в порядке постановки; повторы возвращаются в начало
+	global      *bucket
+	chats       map[int64]*bucket
+	inflight    map[int64]bool // в чат уже идёт отправка: сообщения одного чата уходят по порядку
+	pausedUntil time.Time      // до этого момента Telegram просил не отправлять ничего (429)
+	stopped     bool
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New создаёт очередь и запускает её отправку.
+func New(api *tgbotapi.BotAPI, opts Options) *Queue {
+	opts.normalize()
+	q := &Queue{
+		api:      api,
+		opts:     opts,
+		global:   newBucket(opts.GlobalRate, opts.GlobalBurst, time.Now()),
+		chats:    make(map[int64]*bucket),
+		inflight: make(map[int64]bool),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+// Send ставит сообщение в очередь и ждёт, пока оно будет отправлено или окончательно не удастся.
+func (q *Queue) Send(chatID int64, msg tgbotapi.Chattable) (tgbotapi.Message, error) {
+	type result struct {
+		msg tgbotapi.Message
+		err error
+	}
+	done := make(chan result, 1)
+	q.Enqueue(chatID, msg, func(m tgbotapi.Message, err error) { done <- result{m, err} })
+	r := <-done
+	return r.msg, r.err
+}
+
+// Enqueue ставит сообщение в очередь и не ждёт отправки. done (может быть nil) вызывается
+// один раз, когда сообщение отправлено или окончательно не удалось; если очередь
+// переполнена или остановлена — сразу, из Enqueue.
+func (q *Queue) Enqueue(chatID int64, msg tgbotapi.Chattable, done func(tgbotapi.Message, error)) {
+	j := &job{chatID: chatID, msg: msg, done: done}
+	q.mu.Lock()
+	switch {
+	case q.stopped:
+		q.mu.Unlock()
+		j.finish(tgbotapi.Message{}, ErrStopped)
+		return
+	case len(q.pending) >= q.opts.Backlog:
+		q.mu.Unlock()
+		j.finish(tgbotapi.Message{}, ErrBacklogFull)
+		return
+	}
+	q.pending = append(q.pending, j)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// Stop прекращает отправку: ожидающие сообщения завершаются ErrStopped,
+// уже начатые отправки дожидаются ответа Telegram.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return
+	}
+	q.stopped = true
+	q.mu.Unlock()
+	close(q.stop)
+	q.wg.Wait()
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) run() {
+	defer q.wg.Done()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		j, wait := q.nextLocked(time.Now())
+		q.mu.Unlock()
+		if j != nil {
+			q.wg.Add(1)
+			go q.attempt(j)
+			continue
+		}
+
+		var fire <-chan time.Time
+		if wait > 0 {
+			timer.Reset(wait)
+			fire = timer.C
+		}
+		select {
+		case <-q.stop:
+			q.mu.Lock()
+			pending := q.pending
+			q.pending = nil
+			q.mu.Unlock()
+			// done вызывается без блокировки: обработчик может сразу поставить новое сообщение.
+			for _, j := range pending {
+				j.finish(tgbotapi.Message{}, ErrStopped)
+			}
+			return
+		case <-q.wake:
+		case <-fire:
+		}
+	}
+}
+
+// nextLocked забирает из очереди первое сообщение, которое можно отправить сейчас.
+// Иначе возвращает, сколько ждать до ближайшего готового (0 — ждать постановки нового).
+func (q *Queue) nextLocked(now time.Time) (*job, time.Duration) {
+	if len(q.pending) == 0 {
+		return nil, 0
+	}
+	ready := q.global.readyAt(now)
+	if q.pausedUntil.After(ready) {
+		ready = q.pausedUntil
+	}
+	if ready.After(now) {
+		return nil, ready.Sub(now)
+	}
+
+	var earliest time.Time
+	seen := make(map[int64]bool)
+	for i, j := range q.pending {
+		// Из каждого чата рассматривается только самое раннее сообщение, чтобы не нарушить порядок.
+		if seen[j.chatID] || q.inflight[j.chatID] {
+			seen[j.chatID] = true
+			continue
+		}
+		seen[j.chatID] = true
+		chat, ok := q.chats[j.chatID]
+		if !ok {
+			chat = newBucket(q.opts.ChatRate, q.opts.ChatBurst, now)
+			q.chats[j.chatID] = chat
+		}
+		at := chat.readyAt(now)
+		if j.notBefore.After(at) {
+			at = j.notBefore
+		}
+		if !at.After(now) {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			q.global.take(now)
+			chat.take(now)
+			q.inflight[j.chatID] = true
+			return j, 0
+		}
+		if earliest.IsZero() || at.Before(earliest) {
+			earliest = at
+		}
+	}
+	if earliest.IsZero() {
+		return nil, 0 // все чаты с сообщениями заняты; разбудит завершение отправки
+	}
+	return nil, earliest.Sub(now)
+}
+
+func (q *Queue) attempt(j *job) {
+	defer q.wg.Done()
+	msg, err := q.api.Send(j.msg)
+
+	q.mu.Lock()
+	delete(q.inflight, j.chatID)
+	q.cleanupLocked()
+	if delay, retry := q.retryDelay(err, j.attempts); retry && !q.stopped {
+		j.attempts++
+		j.notBefore = time.Now().Add(delay)
+		// retry_after относится к боту целиком: до его истечения не отправляем никому.
+		if isFlood(err) && j.notBefore.After(q.pausedUntil) {
+			q.pausedUntil = j.notBefore
+		}
+		q.pending = append([]*job{j}, q.pending...)
+		q.mu.Unlock()
+		q.signal()
+		return
+	}
+	q.mu.Unlock()
+
+	j.finish(msg, err)
+	q.signal()
+}
+
+// retryDelay решает, повторять ли отправку, и через сколько.
+func (q *Queue) retryDelay(err error, attempts int) (time.Duration, bool) {
+	if err == nil || attempts >= q.opts.MaxRetries {
+		return 0, false
+	}
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.RetryAfter > 0:
+			return time.Duration(apiErr.RetryAfter) * time.Second, true
+		case apiErr.Code == 0:
+			// При загрузке файлов библиотека не заполняет Code: ошибка может быть и 5xx,
+			// поэтому повторяем всё, кроме ошибок, окончательных для чата.
+			if _, _, final := Undeliverable(err); final {
+				return 0, false
+			}
+		case apiErr.Code < 500:
+			// Ошибки запроса (чат не найден, бот заблокирован, неверная разметка) повтором не исправить.
+			return 0, false
+		}
+	}
+	delay := q.opts.BaseBackoff << attempts
+	if delay <= 0 || delay > q.opts.MaxBackoff {
+		delay = q.opts.MaxBackoff
+	}
+	return delay, true
+}
+
+func isFlood(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.RetryAfter > 0
+}
+
+// cleanupLocked удаляет корзины чатов, которые успели полностью наполниться:
+// они не отличаются от новых, а держать их для каждого когда-либо писавшего чата незачем.
+func (q *Queue) cleanupLocked() {
+	if len(q.chats) < 1024 {
+		return
+	}
+	now := time.Now()
+	for id, b := range q.chats {
+		if b.refill(now); b.tokens >= b.burst && !q.inflight[id] {
+			delete(q.chats, id)
+		}
+	}
+}
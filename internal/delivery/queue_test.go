@@ -0,0 +1,241 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeAPI — сервер Bot API: отвечает на getMe и sendMessage, ответ на отправку задаёт reply.
+type fakeAPI struct {
+	mu    sync.Mutex
+	sent  []string // chat_id|text в порядке приёма
+	times []time.Time
+	reply func(call int, chatID, text string) string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/getMe") {
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+		return
+	}
+	// FormValue разбирает и обычные формы, и multipart при загрузке файлов.
+	chatID, text := r.FormValue("chat_id"), r.FormValue("text")
+	f.mu.Lock()
+	call := len(f.sent)
+	f.sent = append(f.sent, chatID+"|"+text)
+	f.times = append(f.times, time.Now())
+	reply := f.reply
+	f.mu.Unlock()
+	if reply != nil {
+		if body := reply(call, chatID, text); body != "" {
+			fmt.Fprint(w, body)
+			return
+		}
+	}
+	fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":%s}}}`, call+1, chatID)
+}
+
+func (f *fakeAPI) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+func newQueue(t *testing.T, f *fakeAPI, opts Options) *Queue {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPI: %v", err)
+	}
+	q := New(api, opts)
+	t.Cleanup(q.Stop)
+	return q
+}
+
+func send(q *Queue, chatID int64, text string) error {
+	_, err := q.Send(chatID, tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+func TestQueueHonorsRetryAfter(t *testing.T) {
+	f := &fakeAPI{reply: func(call int, _, _ string) string {
+		if call == 0 {
+			return `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+		}
+		return ""
+	}}
+	q := newQueue(t, f, Options{GlobalRate: 1000, GlobalBurst: 100, ChatRate: 1000, ChatBurst: 100})
+
+	start := time.Now()
+	if err := send(q, 1, "first"); err != nil {
+		t.Fatalf("Send() error after 429: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %v, want at least retry_after=1s", elapsed)
+	}
+	if got := f.calls(); len(got) != 2 {
+		t.Fatalf("calls = %v, want 429 and a retry", got)
+	}
+}
+
+func TestQueueRetriesTransientErrorsOnly(t *testing.T) {
+	f := &fakeAPI{reply: func(call int, chatID, _ string) string {
+		switch {
+		case chatID == "1" && call == 0:
+			return `{"ok":false,"error_code":502,"description":"Bad Gateway"}`
+		case chatID == "2":
+			return `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+		}
+		return ""
+	}}
+	q := newQueue(t, f, Options{BaseBackoff: 10 * time.Millisecond, GlobalRate: 1000, ChatRate: 1000})
+
+	if err := send(q, 1, "transient"); err != nil {
+		t.Fatalf("Send() after 502 error: %v", err)
+	}
+	if err := send(q, 2, "blocked"); err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Fatalf("Send() to blocked chat error = %v", err)
+	}
+	if got := f.calls(); len(got) != 3 {
+		t.Fatalf("calls = %v, want 502 + retry + single 403", got)
+	}
+}
+
+func TestQueueRetriesFailedUploads(t *testing.T) {
+	f := &fakeAPI{reply: func(call int, chatID, _ string) string {
+		switch {
+		case chatID == "1" && call == 0:
+			return `{"ok":false,"error_code":502,"description":"Bad Gateway"}`
+		case chatID == "2":
+			return `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+		}
+		return ""
+	}}
+	q := newQueue(t, f, Options{BaseBackoff: 10 * time.Millisecond, GlobalRate: 1000, ChatRate: 1000})
+	photo := func(chatID int64) error {
+		_, err := q.Send(chatID, tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "chart.png", Bytes: []byte("\x89PNG")}))
+		return err
+	}
+
+	// Ошибка загрузки файла приходит без кода: 502 нужно повторить, блокировку — нет.
+	if err := photo(1); err != nil {
+		t.Fatalf("Send() photo after 502 error: %v", err)
+	}
+	if err := photo(2); err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Fatalf("Send() photo to blocked chat error = %v", err)
+	}
+	if got := f.calls(); len(got) != 3 {
+		t.Fatalf("calls = %v, want 502 + retry + single 403", got)
+	}
+}
+
+func TestQueueRateLimits(t *testing.T) {
+	f := &fakeAPI{}
+	// Глобально 20/с без запаса; в один чат — 10/с.
+	q := newQueue(t, f, Options{GlobalRate: 20, GlobalBurst: 1, ChatRate: 10, ChatBurst: 1})
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for chat := int64(1); chat <= 4; chat++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := send(q, chat, "global"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// Первое сообщение уходит сразу, следующие три — с шагом 50 мс.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Fatalf("4 messages at 20/s took %v", elapsed)
+	}
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := send(q, 9, strconv.Itoa(i)); err != nil {
+				t.Error(err)
+			}
+		}()
+		time.Sleep(5 * time.Millisecond) // порядок постановки
+	}
+	wg.Wait()
+	calls := f.calls()[4:]
+	if strings.Join(calls, ",") != "9|0,9|1,9|2" {
+		t.Fatalf("per-chat order = %v", calls)
+	}
+	f.mu.Lock()
+	gap := f.times[6].Sub(f.times[5])
+	f.mu.Unlock()
+	if gap < 80*time.Millisecond {
+		t.Fatalf("messages to one chat %v apart, want ~100ms at 10/s", gap)
+	}
+}
+
+func TestQueueBacklogIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	f := &fakeAPI{reply: func(call int, _, _ string) string {
+		if call == 0 {
+			<-release
+		}
+		return ""
+	}}
+	q := newQueue(t, f, Options{Backlog: 1, GlobalRate: 1000, ChatRate: 1000})
+
+	results := make(chan error, 2)
+	go func() { results <- send(q, 1, "in flight") }()
+	time.Sleep(50 * time.Millisecond)
+	go func() { results <- send(q, 1, "pending") }()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := send(q, 1, "overflow"); err != ErrBacklogFull {
+		t.Fatalf("Send() with full backlog error = %v, want ErrBacklogFull", err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("queued message error: %v", err)
+		}
+	}
+}
+
+func TestQueueEnqueueDoesNotWait(t *testing.T) {
+	release := make(chan struct{})
+	f := &fakeAPI{reply: func(int, string, string) string {
+		<-release
+		return ""
+	}}
+	q := newQueue(t, f, Options{GlobalRate: 1000, ChatRate: 1000})
+
+	done := make(chan error, 1)
+	start := time.Now()
+	q.Enqueue(1, tgbotapi.NewMessage(1, "reply"), func(_ tgbotapi.Message, err error) { done <- err })
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Enqueue() waited %v for the send", elapsed)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("done got error %v", err)
+	}
+
+	q.Stop()
+	var stopped error
+	q.Enqueue(1, tgbotapi.NewMessage(1, "late"), func(_ tgbotapi.Message, err error) { stopped = err })
+	if !errors.Is(stopped, ErrStopped) {
+		t.Fatalf("Enqueue() after Stop reported %v, want ErrStopped", stopped)
+	}
+}
@@ -0,0 +1,132 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	binanceFuturesHost       = "https://fapi.binance.com"
+	binanceExchangeInfoPath  = "/fapi/v1/exchangeInfo"
+	binanceKlinePathFmt      = "/fapi/v1/klines?symbol=%s&interval=%s&limit=%d"
+	binanceTimePath          = "/fapi/v1/time"
+	binanceMaxKlineLimit     = 1500
+	binanceContractPerpetual = "PERPETUAL"
+)
+
+var binanceIntervals = map[string]string{
+	"1":   "1m",
+	"5":   "5m",
+	"15":  "15m",
+	"60":  "1h",
+	"240": "4h",
+	"D":   "1d",
+}
+
+// BinanceExchange — бессрочные USDⓈ-M фьючерсы Binance.
+type BinanceExchange struct {
+	// Host — базовый адрес futures API.
+	Host string
+}
+
+// NewBinance создаёт адаптер Binance USDⓈ-M.
+func NewBinance() *BinanceExchange {
+	return &BinanceExchange{Host: binanceFuturesHost}
+}
+
+// Name возвращает "binance".
+func (b *BinanceExchange) Name() string {
+	return Binance
+}
+
+// DerivativePairs возвращает бессрочные контракты Binance USDⓈ-M в статусе TRADING.
+func (b *BinanceExchange) DerivativePairs() ([]string, error) {
+	body, err := httpGET("binance", b.Host+binanceExchangeInfoPath, 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Symbols []struct {
+			Symbol       string `json:"symbol"`
+			Status       string `json:"status"`
+			ContractType string `json:"contractType"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Binance (pairs): %w", err)
+	}
+
+	var result []string
+	for _, s := range data.Symbols {
+		if s.Status == "TRADING" && s.ContractType == binanceContractPerpetual {
+			result = append(result, s.Symbol)
+		}
+	}
+	return result, nil
+}
+
+// Candles запрашивает свечи Binance USDⓈ-M; Binance отдаёт их уже в порядке старые → новые.
+func (b *BinanceExchange) Candles(symbol, timeframe string, limit int) ([]Candle, error) {
+	interval, ok := binanceIntervals[timeframe]
+	if !ok {
+		return nil, fmt.Errorf("binance: неподдерживаемый таймфрейм %q", timeframe)
+	}
+	if limit > binanceMaxKlineLimit {
+		limit = binanceMaxKlineLimit
+	}
+	body, err := httpGET("binance", b.Host+fmt.Sprintf(binanceKlinePathFmt, symbol, interval, limit), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Binance (candles): %w", err)
+	}
+
+	now := time.Now()
+	candles := make([]Candle, 0, len(rows))
+	for i, row := range rows {
+		// [openTime, open, high, low, close, volume, closeTime, quoteAssetVolume, ...]
+		if len(row) < 8 {
+			return nil, fmt.Errorf("binance candles %s: строка %d: ожидалось 8 полей, получено %d", symbol, i, len(row))
+		}
+		var openMs, closeMs int64
+		if err := json.Unmarshal(row[0], &openMs); err != nil {
+			return nil, fmt.Errorf("binance candles %s: строка %d: поле openTime: %w", symbol, i, err)
+		}
+		if err := json.Unmarshal(row[6], &closeMs); err != nil {
+			return nil, fmt.Errorf("binance candles %s: строка %d: поле closeTime: %w", symbol, i, err)
+		}
+		var fields [6]string
+		for j, idx := range []int{1, 2, 3, 4, 5, 7} {
+			if err := json.Unmarshal(row[idx], &fields[j]); err != nil {
+				return nil, fmt.Errorf("binance candles %s: строка %d: поле %d: %w", symbol, i, idx, err)
+			}
+		}
+		c, err := parseCandle(openMs, fields[0], fields[1], fields[2], fields[3], fields[4], fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("binance candles %s: строка %d: %w", symbol, i, err)
+		}
+		c.Confirmed = time.UnixMilli(closeMs).Before(now)
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
+
+// ServerTime возвращает время сервера Binance.
+func (b *BinanceExchange) ServerTime() (time.Time, error) {
+	body, err := httpGET("binance", b.Host+binanceTimePath, 10*time.Second)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var data struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return time.Time{}, fmt.Errorf("ошибка парсинга ответа Binance (time): %w", err)
+	}
+	return time.UnixMilli(data.ServerTime), nil
+}
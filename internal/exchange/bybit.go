@@ -0,0 +1,362 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	bybitInstrumentsPath = "/v5/market/instruments-info?category=linear&limit=1000"
+	// bybitInstrumentsMaxPages — предохранитель от курсора, который никогда не заканчивается.
+	bybitInstrumentsMaxPages = 50
+	// bybitInstrumentsRefresh — как долго NewBybit держит список контрактов без обновления.
+	bybitInstrumentsRefresh = 10 * time.Minute
+	bybitKlinePathFmt       = "/v5/market/kline?category=linear&symbol=%s&interval=%s&limit=%d"
+	bybitKlineRangeFmt      = "/v5/market/kline?category=linear&symbol=%s&interval=%s&start=%d&end=%d&limit=%d"
+	// bybitKlineMaxLimit — максимум свечей в одном ответе /v5/market/kline.
+	bybitKlineMaxLimit = 1000
+	bybitTimePath      = "/v5/market/time"
+	bybitTickersPath   = "/v5/market/tickers?category=linear"
+)
+
+var bybitMainnetHosts = []string{
+	"https://api.bybit.com",
+	"https://api.bytick.com",
+	"https://api.bybit.kz",
+	"https://api.bybit-tr.com",
+	"https://api.bybit.ae",
+}
+
+// BybitExchange — линейные деривативы Bybit v5 (category=linear).
+type BybitExchange struct {
+	// Hosts — домены API, которые пробуются по очереди.
+	Hosts []string
+	// InstrumentsRefresh — интервал обновления кэша контрактов; 0 — запрашивать при каждом вызове.
+	InstrumentsRefresh time.Duration
+	// Context прерывает ожидание лимита запросов; nil — ожидание не прерывается.
+	Context context.Context
+
+	mu            sync.Mutex
+	instruments   []Instrument
+	instrumentsAt time.Time
+
+	limiter bybitLimiter
+}
+
+// NewBybit создаёт адаптер Bybit с официальными mainnet-доменами.
+func NewBybit() *BybitExchange {
+	return &BybitExchange{Hosts: bybitMainnetHosts, InstrumentsRefresh: bybitInstrumentsRefresh}
+}
+
+// Name возвращает "bybit".
+func (b *BybitExchange) Name() string {
+	return Bybit
+}
+
+// DerivativePairs возвращает список символов линейных деривативов Bybit (category=linear) в статусе Trading.
+func (b *BybitExchange) DerivativePairs() ([]string, error) {
+	instruments, err := b.Instruments()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(instruments))
+	for _, in := range instruments {
+		if in.Trading() {
+			result = append(result, in.Symbol)
+		}
+	}
+	return result, nil
+}
+
+// Instruments возвращает все линейные контракты Bybit со статусом и метаданными.
+// Список запрашивается постранично до конца курсора и кэшируется на InstrumentsRefresh;
+// если обновить его не удалось, отдаётся прошлый список. Кэш живёт в адаптере, поэтому
+// сканер и боты одной биржи должны работать через один BybitExchange.
+func (b *BybitExchange) Instruments() ([]Instrument, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.instruments != nil && time.Since(b.instrumentsAt) < b.InstrumentsRefresh {
+		return b.instruments, nil
+	}
+	instruments, err := b.fetchInstruments()
+	if err != nil {
+		if b.instruments != nil {
+			log.Printf("[bybit] Не удалось обновить список контрактов, используется прежний: %v", err)
+			return b.instruments, nil
+		}
+		return nil, err
+	}
+	b.instruments, b.instrumentsAt = instruments, time.Now()
+	return instruments, nil
+}
+
+func (b *BybitExchange) fetchInstruments() ([]Instrument, error) {
+	var result []Instrument
+	seen := make(map[string]bool)
+	cursor := ""
+	for page := 0; ; page++ {
+		if page == bybitInstrumentsMaxPages {
+			return nil, fmt.Errorf("bybit pairs: больше %d страниц списка контрактов", bybitInstrumentsMaxPages)
+		}
+		path := bybitInstrumentsPath
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		body, err := b.getAny(path, 15*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		var data struct {
+			RetCode int    `json:"retCode"`
+			RetMsg  string `json:"retMsg"`
+			Result  struct {
+				List []struct {
+					Symbol       string `json:"symbol"`
+					Status       string `json:"status"`
+					ContractType string `json:"contractType"`
+					BaseCoin     string `json:"baseCoin"`
+					QuoteCoin    string `json:"quoteCoin"`
+					LaunchTime   string `json:"launchTime"`
+					PriceFilter  struct {
+						TickSize string `json:"tickSize"`
+					} `json:"priceFilter"`
+				} `json:"list"`
+				NextPageCursor string `json:"nextPageCursor"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга ответа Bybit (pairs): %w", err)
+		}
+		if data.RetCode != 0 {
+			return nil, fmt.Errorf("bybit pairs retCode=%d retMsg=%s", data.RetCode, data.RetMsg)
+		}
+
+		for _, s := range data.Result.List {
+			if seen[s.Symbol] {
+				continue
+			}
+			seen[s.Symbol] = true
+			in := Instrument{
+				Symbol:       s.Symbol,
+				BaseCoin:     s.BaseCoin,
+				QuoteCoin:    s.QuoteCoin,
+				ContractType: s.ContractType,
+				Status:       s.Status,
+			}
+			if ms, err := strconv.ParseInt(s.LaunchTime, 10, 64); err == nil && ms > 0 {
+				in.LaunchTime = time.UnixMilli(ms)
+			}
+			if tick, err := strconv.ParseFloat(s.PriceFilter.TickSize, 64); err == nil {
+				in.TickSize = tick
+			}
+			result = append(result, in)
+		}
+
+		next := data.Result.NextPageCursor
+		if next == "" || next == cursor {
+			return result, nil
+		}
+		cursor = next
+	}
+}
+
+// Turnover24h возвращает оборот линейных контрактов Bybit за 24 часа в валюте котировки.
+func (b *BybitExchange) Turnover24h() (map[string]float64, error) {
+	body, err := b.getAny(bybitTickersPath, 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List []struct {
+				Symbol      string `json:"symbol"`
+				Turnover24h string `json:"turnover24h"`
+			} `json:"list"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Bybit (tickers): %w", err)
+	}
+	if data.RetCode != 0 {
+		return nil, fmt.Errorf("bybit tickers retCode=%d retMsg=%s", data.RetCode, data.RetMsg)
+	}
+
+	result := make(map[string]float64, len(data.Result.List))
+	for _, t := range data.Result.List {
+		turnover, err := strconv.ParseFloat(t.Turnover24h, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bybit tickers %s: turnover24h=%q: %w", t.Symbol, t.Turnover24h, err)
+		}
+		result[t.Symbol] = turnover
+	}
+	return result, nil
+}
+
+// Candles запрашивает свечи с Bybit (linear) и возвращает их в хронологическом порядке (старые → новые).
+// symbol — тикер, например BTCUSDT; timeframe — интервал: "5", "15", "60", "240" и т.д.; limit — число свечей.
+// Последняя свеча Bybit — текущая, она помечается незакрытой, пока не истечёт её интервал.
+// Некорректная строка ответа — ошибка, а не пропуск.
+func (b *BybitExchange) Candles(symbol, timeframe string, limit int) ([]Candle, error) {
+	return b.klines(fmt.Sprintf(bybitKlinePathFmt, symbol, timeframe, limit), symbol, timeframe)
+}
+
+// CandlesRange загружает историю свечей с открытием в [start, end] постранично
+// (по 1000 свечей, от конца диапазона к началу) и возвращает её в хронологическом порядке.
+func (b *BybitExchange) CandlesRange(symbol, timeframe string, start, end time.Time) ([]Candle, error) {
+	var pages [][]Candle
+	total := 0
+	for !end.Before(start) {
+		page, err := b.klines(fmt.Sprintf(bybitKlineRangeFmt, symbol, timeframe, start.UnixMilli(), end.UnixMilli(), bybitKlineMaxLimit), symbol, timeframe)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		total += len(page)
+		first := page[0].OpenTime
+		if !first.Before(end) {
+			break // биржа игнорирует end — дальше листать нечего
+		}
+		end = first.Add(-time.Millisecond)
+	}
+
+	candles := make([]Candle, 0, total)
+	for i := len(pages) - 1; i >= 0; i-- {
+		for _, c := range pages[i] {
+			if c.OpenTime.Before(start) {
+				continue
+			}
+			if n := len(candles); n > 0 && !c.OpenTime.After(candles[n-1].OpenTime) {
+				continue
+			}
+			candles = append(candles, c)
+		}
+	}
+	return candles, nil
+}
+
+// klines выполняет запрос /v5/market/kline и разворачивает ответ в хронологический порядок.
+func (b *BybitExchange) klines(pathAndQuery, symbol, timeframe string) ([]Candle, error) {
+	body, err := b.getAny(pathAndQuery, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List [][]string `json:"list"`
+		} `json:"result"`
+	}
+
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Bybit (candles): %w", err)
+	}
+	if data.RetCode != 0 {
+		return nil, fmt.Errorf("bybit candles retCode=%d retMsg=%s", data.RetCode, data.RetMsg)
+	}
+
+	now := time.Now()
+	candles := make([]Candle, 0, len(data.Result.List))
+	for i := len(data.Result.List) - 1; i >= 0; i-- {
+		row := data.Result.List[i]
+		if len(row) < 7 {
+			return nil, fmt.Errorf("bybit candles %s: строка %d: ожидалось 7 полей, получено %d", symbol, i, len(row))
+		}
+		start, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bybit candles %s: строка %d: поле start=%q: %w", symbol, i, row[0], err)
+		}
+		c, err := parseCandle(start, row[1], row[2], row[3], row[4], row[5], row[6])
+		if err != nil {
+			return nil, fmt.Errorf("bybit candles %s: строка %d: %w", symbol, i, err)
+		}
+		c.Confirmed = closedBy(c.OpenTime, timeframe, now)
+		candles = append(candles, c)
+	}
+
+	return candles, nil
+}
+
+// ServerTime возвращает время сервера Bybit.
+func (b *BybitExchange) ServerTime() (time.Time, error) {
+	body, err := b.getAny(bybitTimePath, 10*time.Second)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var data struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			TimeNano string `json:"timeNano"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return time.Time{}, fmt.Errorf("ошибка парсинга ответа Bybit (time): %w", err)
+	}
+	if data.RetCode != 0 {
+		return time.Time{}, fmt.Errorf("bybit time retCode=%d retMsg=%s", data.RetCode, data.RetMsg)
+	}
+	nanos, err := strconv.ParseInt(data.Result.TimeNano, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bybit time: %w", err)
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// getAny пробует выполнить запрос к нескольким официальным mainnet-доменам Bybit.
+// Это нужно, потому что некоторые регионы/сети могут получать 403 на api.bybit.com.
+// Запросы проходят через ограничитель: при retCode 10006 запрос повторяется после паузы.
+func (b *BybitExchange) getAny(pathAndQuery string, timeout time.Duration) ([]byte, error) {
+	ctx := b.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for attempt := 0; ; attempt++ {
+		if err := b.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		body, header, err := b.getAnyHost(pathAndQuery, timeout)
+		if err != nil {
+			return nil, err
+		}
+		var status struct {
+			RetCode int `json:"retCode"`
+		}
+		if json.Unmarshal(body, &status) != nil || status.RetCode != bybitRateLimitCode {
+			b.limiter.observe(header, time.Now())
+			return body, nil
+		}
+		if attempt == bybitRateLimitRetries {
+			return body, nil // retCode 10006 разберёт вызывающий
+		}
+		b.limiter.throttled(header, time.Now())
+		log.Printf("[bybit] Превышен лимит запросов (retCode %d), повтор после паузы", bybitRateLimitCode)
+	}
+}
+
+func (b *BybitExchange) getAnyHost(pathAndQuery string, timeout time.Duration) ([]byte, http.Header, error) {
+	var lastErr error
+	for _, host := range b.Hosts {
+		body, header, err := httpGETWithHeader("bybit", host+pathAndQuery, timeout)
+		if err == nil {
+			return body, header, nil
+		}
+		lastErr = err
+	}
+	return nil, nil, fmt.Errorf("не удалось получить ответ Bybit ни с одного домена: %w", lastErr)
+}
@@ -0,0 +1,129 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// bybitRateLimitCode — retCode ответа Bybit «слишком много запросов».
+	bybitRateLimitCode = 10006
+	// bybitRateLimitRetries — сколько раз повторить запрос после retCode 10006.
+	bybitRateLimitRetries = 3
+	// bybitLowRemaining — с какого остатка окна растягивать запросы, если биржа не сообщила размер окна.
+	bybitLowRemaining = 5
+	bybitMinBackoff   = time.Second
+	bybitMaxBackoff   = 30 * time.Second
+	// bybitResumeInterval — промежуток между запросами после паузы из-за retCode 10006.
+	bybitResumeInterval = 100 * time.Millisecond
+)
+
+// bybitLimiter притормаживает запросы к Bybit: по заголовкам X-Bapi-Limit-Status
+// (сколько запросов осталось в окне) и X-Bapi-Limit-Reset-Timestamp (когда окно обновится)
+// растягивает остаток окна до его сброса, а после retCode 10006 ждёт сброса окна
+// или растущую паузу, если биржа его не сообщила. Запросы всех потоков получают
+// очередь по одному с интервалом между ними, поэтому после паузы они не уходят пачкой.
+// Нулевое значение готово к работе.
+type bybitLimiter struct {
+	mu sync.Mutex
+	// next — когда можно отправить следующий запрос.
+	next time.Time
+	// interval — промежуток между запросами до spreadUntil; после него запросы не растягиваются.
+	interval    time.Duration
+	spreadUntil time.Time
+	backoff     time.Duration
+}
+
+// wait занимает очередь на запрос и ждёт её; ошибка — ctx отменён раньше.
+func (l *bybitLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := now
+	if l.next.After(at) {
+		at = l.next
+	}
+	if at.Before(l.spreadUntil) {
+		l.next = at.Add(l.interval)
+	}
+	l.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// observe учитывает заголовки лимита успешного ответа.
+func (l *bybitLimiter) observe(h http.Header, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backoff = 0
+	remaining, err := strconv.Atoi(h.Get("X-Bapi-Limit-Status"))
+	if err != nil {
+		return
+	}
+	reset, ok := parseResetTimestamp(h, now)
+	if !ok {
+		return
+	}
+	// Пока в окне больше четверти запросов, не мешаем; дальше делим остаток окна поровну.
+	// Без X-Bapi-Limit порогом служат последние bybitLowRemaining запросов.
+	low := remaining <= bybitLowRemaining
+	if limit, err := strconv.Atoi(h.Get("X-Bapi-Limit")); err == nil && limit > 0 {
+		low = remaining*4 <= limit
+	}
+	if !low {
+		l.interval, l.spreadUntil = 0, time.Time{}
+		return
+	}
+	l.interval = reset.Sub(now) / time.Duration(remaining+1)
+	l.spreadUntil = reset
+	l.delayLocked(now.Add(l.interval))
+}
+
+// throttled учитывает ответ retCode 10006 и решает, через сколько повторить запрос.
+// После паузы запросы идут не чаще раза в bybitResumeInterval, пока ответы не покажут
+// свободное окно.
+func (l *bybitLimiter) throttled(h http.Header, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := parseResetTimestamp(h, now)
+	if !ok {
+		l.backoff = min(max(2*l.backoff, bybitMinBackoff), bybitMaxBackoff)
+		until = now.Add(l.backoff)
+	}
+	l.delayLocked(until)
+	l.interval = bybitResumeInterval
+	l.spreadUntil = until.Add(bybitMaxBackoff)
+}
+
+func (l *bybitLimiter) delayLocked(until time.Time) {
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+// parseResetTimestamp возвращает момент сброса окна лимита, если он ещё не наступил.
+func parseResetTimestamp(h http.Header, now time.Time) (time.Time, bool) {
+	ms, err := strconv.ParseInt(h.Get("X-Bapi-Limit-Reset-Timestamp"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	reset := time.UnixMilli(ms)
+	// Часы биржи и наши расходятся: слишком далёкий сброс считаем ошибкой и не ждём его.
+	if !reset.After(now) || reset.Sub(now) > bybitMaxBackoff {
+		return time.Time{}, false
+	}
+	return reset, true
+}
@@ -0,0 +1,120 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBybitRetriesAfterRateLimit(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			reset := time.Now().Add(300 * time.Millisecond).UnixMilli()
+			w.Header().Set("X-Bapi-Limit-Reset-Timestamp", strconv.FormatInt(reset, 10))
+			fmt.Fprint(w, `{"retCode":10006,"retMsg":"Too many visits!"}`)
+			return
+		}
+		fmt.Fprint(w, `{"retCode":0,"result":{"list":[["1","10","11","9","10","5","50"]]}}`)
+	}))
+	t.Cleanup(srv.Close)
+	ex := &BybitExchange{Hosts: []string{srv.URL}}
+
+	start := time.Now()
+	candles, err := ex.Candles("BTCUSDT", "60", 1)
+	if err != nil || len(candles) != 1 {
+		t.Fatalf("Candles() = %v, %v after retCode 10006", candles, err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Fatalf("retried after %v, want to wait for the limit reset", elapsed)
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d, want 10006 and a retry", calls)
+	}
+}
+
+func TestBybitLimiterSpreadsRemainingRequests(t *testing.T) {
+	now := time.Now()
+	header := func(limit, remaining int, reset time.Duration) http.Header {
+		h := http.Header{}
+		h.Set("X-Bapi-Limit", strconv.Itoa(limit))
+		h.Set("X-Bapi-Limit-Status", strconv.Itoa(remaining))
+		h.Set("X-Bapi-Limit-Reset-Timestamp", strconv.FormatInt(now.Add(reset).UnixMilli(), 10))
+		return h
+	}
+
+	var l bybitLimiter
+	l.observe(header(120, 100, time.Second), now)
+	if !l.next.IsZero() || l.interval != 0 {
+		t.Fatalf("next = %v, interval = %v with most of the window left", l.next, l.interval)
+	}
+	l.observe(header(120, 9, time.Second), now)
+	if l.interval < 90*time.Millisecond || l.interval > 110*time.Millisecond {
+		t.Fatalf("interval = %v, want the second left spread over 10 requests", l.interval)
+	}
+
+	var backoff bybitLimiter
+	backoff.throttled(http.Header{}, now)
+	backoff.throttled(http.Header{}, now)
+	if pause := backoff.next.Sub(now); pause != 2*bybitMinBackoff {
+		t.Fatalf("pause after two 10006 without reset = %v, want %v", pause, 2*bybitMinBackoff)
+	}
+}
+
+func TestBybitLimiterSpacesWaitersAfterPause(t *testing.T) {
+	var l bybitLimiter
+	start := time.Now()
+	l.mu.Lock()
+	l.next = start.Add(50 * time.Millisecond)
+	l.interval = 30 * time.Millisecond
+	l.spreadUntil = start.Add(time.Second)
+	l.mu.Unlock()
+
+	const waiters = 4
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var times []time.Duration
+	for range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			times = append(times, time.Since(start))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	slices.Sort(times)
+	// Первый запрос уходит по окончании паузы, остальные — через interval друг за другом, а не пачкой.
+	if times[0] < 45*time.Millisecond {
+		t.Fatalf("first request at %v, before the pause ended", times[0])
+	}
+	if last := times[waiters-1]; last < 50*time.Millisecond+(waiters-1)*30*time.Millisecond-5*time.Millisecond {
+		t.Fatalf("requests at %v, want them %v apart", times, 30*time.Millisecond)
+	}
+}
+
+func TestBybitLimiterWaitStopsOnCancel(t *testing.T) {
+	var l bybitLimiter
+	l.throttled(http.Header{}, time.Now().Add(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := l.wait(ctx); err == nil {
+		t.Fatal("wait() = nil after ctx was cancelled during the pause")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait() returned after %v, want right after cancel", elapsed)
+	}
+}
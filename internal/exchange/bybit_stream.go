@@ -0,0 +1,350 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	bybitPublicLinearWS = "wss://stream.bybit.com/v5/public/linear"
+	// Bybit принимает ограниченное число топиков в одном запросе subscribe.
+	bybitWSArgsPerRequest = 10
+)
+
+// StreamKey — пара (символ, таймфрейм), на свечи которой подписан поток.
+type StreamKey struct {
+	Symbol    string
+	Timeframe string
+}
+
+func (k StreamKey) topic() string {
+	return "kline." + k.Timeframe + "." + k.Symbol
+}
+
+// KlineUpdate — обновление свечи из WebSocket: актуальный буфер свечей (старые → новые),
+// последняя из которых может быть ещё не закрыта.
+type KlineUpdate struct {
+	StreamKey
+	Candles []Candle
+}
+
+type streamBuffer struct {
+	bars  []Candle
+	ready bool // буфер заполнен историей через REST
+}
+
+// BybitStream поддерживает подписку на публичные топики kline.{interval}.{symbol} Bybit,
+// держит скользящие буферы свечей и после переподключения переподписывается
+// и догружает историю через REST.
+type BybitStream struct {
+	// URL — адрес публичного WebSocket linear.
+	URL string
+	// REST — адаптер Bybit для загрузки истории.
+	REST *BybitExchange
+	// Limit — размер буфера свечей на топик.
+	Limit int
+	// PingInterval — период heartbeat; соединение считается мёртвым после двух пропущенных.
+	PingInterval time.Duration
+	// ReconnectDelay — начальная пауза перед переподключением, растёт до минуты.
+	ReconnectDelay time.Duration
+
+	mu      sync.Mutex
+	topics  map[string]StreamKey
+	buffers map[string]*streamBuffer
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// NewBybitStream создаёт поток для mainnet с буфером limit свечей.
+func NewBybitStream(rest *BybitExchange, limit int) *BybitStream {
+	return &BybitStream{
+		URL:            bybitPublicLinearWS,
+		REST:           rest,
+		Limit:          limit,
+		PingInterval:   20 * time.Second,
+		ReconnectDelay: time.Second,
+		topics:         make(map[string]StreamKey),
+		buffers:        make(map[string]*streamBuffer),
+	}
+}
+
+// SetTopics задаёт полный список подписок. На активном соединении сразу отправляются
+// subscribe/unsubscribe, а для новых топиков и топиков без истории догружается история.
+func (s *BybitStream) SetTopics(keys []StreamKey) {
+	want := make(map[string]StreamKey, len(keys))
+	for _, k := range keys {
+		want[k.topic()] = k
+	}
+
+	s.mu.Lock()
+	var added, removed, pending []string
+	for t := range want {
+		if _, ok := s.topics[t]; !ok {
+			added = append(added, t)
+		} else if buf, ok := s.buffers[t]; !ok || !buf.ready {
+			pending = append(pending, t)
+		}
+	}
+	for t := range s.topics {
+		if _, ok := want[t]; !ok {
+			removed = append(removed, t)
+			delete(s.buffers, t)
+		}
+	}
+	s.topics = want
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+	if err := s.send(conn, "unsubscribe", removed); err != nil {
+		log.Printf("[bybit ws] Ошибка отписки: %v", err)
+	}
+	if err := s.send(conn, "subscribe", added); err != nil {
+		log.Printf("[bybit ws] Ошибка подписки: %v", err)
+	}
+	go s.backfill(append(added, pending...))
+}
+
+// Run держит соединение до отмены ctx и вызывает onUpdate на каждое обновление свечи
+// по топику, для которого уже загружена история.
+func (s *BybitStream) Run(ctx context.Context, onUpdate func(KlineUpdate)) {
+	delay := s.ReconnectDelay
+	for {
+		started := time.Now()
+		err := s.session(ctx, onUpdate)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			delay = s.ReconnectDelay
+		}
+		log.Printf("[bybit ws] Соединение потеряно: %v. Переподключение через %s", err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+// session обслуживает одно соединение: подписка, догрузка истории, heartbeat и чтение сообщений.
+func (s *BybitStream) session(ctx context.Context, onUpdate func(KlineUpdate)) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conn = conn
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	// После разрыва буферы могли пропустить свечи — считаем их устаревшими до догрузки.
+	for _, buf := range s.buffers {
+		buf.ready = false
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	sort.Strings(topics)
+	if err := s.send(conn, "subscribe", topics); err != nil {
+		return err
+	}
+	go s.backfill(topics)
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.keepAlive(conn, done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * s.PingInterval))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		for _, upd := range s.handleMessage(msg) {
+			onUpdate(upd)
+		}
+	}
+}
+
+func (s *BybitStream) keepAlive(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(s.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := conn.WriteJSON(map[string]string{"op": "ping"})
+			s.writeMu.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (s *BybitStream) send(conn *websocket.Conn, op string, topics []string) error {
+	for i := 0; i < len(topics); i += bybitWSArgsPerRequest {
+		end := i + bybitWSArgsPerRequest
+		if end > len(topics) {
+			end = len(topics)
+		}
+		s.writeMu.Lock()
+		err := conn.WriteJSON(map[string]any{"op": op, "args": topics[i:end]})
+		s.writeMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfill загружает историю через REST и сливает её с уже пришедшими по WebSocket свечами.
+func (s *BybitStream) backfill(topics []string) {
+	for _, t := range topics {
+		s.mu.Lock()
+		key, ok := s.topics[t]
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+		bars, err := s.REST.Candles(key.Symbol, key.Timeframe, s.Limit)
+		if err != nil {
+			log.Printf("[bybit ws] Ошибка догрузки %s: %v", t, err)
+			continue
+		}
+
+		s.mu.Lock()
+		if _, ok := s.topics[t]; ok {
+			// Из WebSocket берём только свечи новее истории: остальное REST знает точнее,
+			// особенно после разрыва, когда буфер мог устареть.
+			buf := s.buffer(t)
+			for _, b := range buf.bars {
+				if len(bars) == 0 || b.OpenTime.After(bars[len(bars)-1].OpenTime) {
+					bars = append(bars, b)
+				}
+			}
+			buf.bars = trimBars(bars, s.Limit)
+			buf.ready = true
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *BybitStream) buffer(topic string) *streamBuffer {
+	buf, ok := s.buffers[topic]
+	if !ok {
+		buf = &streamBuffer{}
+		s.buffers[topic] = buf
+	}
+	return buf
+}
+
+// handleMessage разбирает сообщение WebSocket и возвращает обновления готовых буферов.
+func (s *BybitStream) handleMessage(msg []byte) []KlineUpdate {
+	var data struct {
+		Topic string `json:"topic"`
+		Data  []struct {
+			Start    int64  `json:"start"`
+			Open     string `json:"open"`
+			High     string `json:"high"`
+			Low      string `json:"low"`
+			Close    string `json:"close"`
+			Volume   string `json:"volume"`
+			Turnover string `json:"turnover"`
+			Confirm  bool   `json:"confirm"`
+		} `json:"data"`
+		Op      string `json:"op"`
+		Success *bool  `json:"success"`
+		RetMsg  string `json:"ret_msg"`
+	}
+	if err := json.Unmarshal(msg, &data); err != nil {
+		log.Printf("[bybit ws] Некорректное сообщение: %v", err)
+		return nil
+	}
+	if data.Success != nil && !*data.Success {
+		log.Printf("[bybit ws] Ошибка операции %s: %s", data.Op, data.RetMsg)
+		return nil
+	}
+	if !strings.HasPrefix(data.Topic, "kline.") {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.topics[data.Topic]
+	if !ok {
+		return nil
+	}
+	buf := s.buffer(data.Topic)
+	var updates []KlineUpdate
+	for _, k := range data.Data {
+		bar, err := parseCandle(k.Start, k.Open, k.High, k.Low, k.Close, k.Volume, k.Turnover)
+		if err != nil {
+			log.Printf("[bybit ws] Некорректная свеча %s: %v", data.Topic, err)
+			continue
+		}
+		bar.Confirmed = k.Confirm
+		buf.bars = trimBars(mergeBar(buf.bars, bar), s.Limit)
+		if !buf.ready {
+			continue
+		}
+		updates = append(updates, KlineUpdate{StreamKey: key, Candles: append([]Candle(nil), buf.bars...)})
+	}
+	return updates
+}
+
+// mergeBar обновляет свечу с тем же временем открытия или добавляет новую, сохраняя порядок.
+func mergeBar(bars []Candle, b Candle) []Candle {
+	for i := len(bars) - 1; i >= 0; i-- {
+		if bars[i].OpenTime.Equal(b.OpenTime) {
+			bars[i] = b
+			return bars
+		}
+		if bars[i].OpenTime.Before(b.OpenTime) {
+			bars = append(bars, Candle{})
+			copy(bars[i+2:], bars[i+1:])
+			bars[i+1] = b
+			return bars
+		}
+	}
+	return append([]Candle{b}, bars...)
+}
+
+func trimBars(bars []Candle, limit int) []Candle {
+	if limit > 0 && len(bars) > limit {
+		return bars[len(bars)-limit:]
+	}
+	return bars
+}
@@ -0,0 +1,130 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newBybitStandIn поднимает REST и WebSocket заглушки Bybit. На каждое соединение WebSocket
+// ждёт subscribe, шлёт одно обновление свечи и, если closeAfterPush, рвёт соединение.
+func newBybitStandIn(t *testing.T, closeAfterPush bool) (*BybitStream, *int32, *int32) {
+	t.Helper()
+	var restCalls, wsSessions int32
+
+	rest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&restCalls, 1)
+		w.Write([]byte(`{"retCode":0,"result":{"list":[["3000","3","3","3","3","1","3"],["2000","2","2","2","2","1","2"],["1000","1","1","1","1","1","1"]]}}`))
+	}))
+	t.Cleanup(rest.Close)
+
+	upgrader := websocket.Upgrader{}
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		atomic.AddInt32(&wsSessions, 1)
+
+		var req struct {
+			Op   string   `json:"op"`
+			Args []string `json:"args"`
+		}
+		if err := conn.ReadJSON(&req); err != nil || req.Op != "subscribe" || !reflect.DeepEqual(req.Args, []string{"kline.60.BTCUSDT"}) {
+			t.Errorf("unexpected subscribe request: %+v, %v", req, err)
+			return
+		}
+		// Даём догрузке истории завершиться, чтобы буфер был готов.
+		time.Sleep(100 * time.Millisecond)
+		push := `{"topic":"kline.60.BTCUSDT","data":[` +
+			`{"start":3000,"open":"3","high":"3.6","low":"3","close":"3.5","volume":"2","turnover":"7","confirm":false},` +
+			`{"start":4000,"open":"3.5","high":"4","low":"3.5","close":"4","volume":"1","turnover":"4","confirm":true}]}`
+		conn.WriteMessage(websocket.TextMessage, []byte(push))
+		if closeAfterPush {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ws.Close)
+
+	st := NewBybitStream(&BybitExchange{Hosts: []string{rest.URL}}, 3)
+	st.URL = "ws" + strings.TrimPrefix(ws.URL, "http")
+	st.ReconnectDelay = 10 * time.Millisecond
+	st.SetTopics([]StreamKey{{Symbol: "BTCUSDT", Timeframe: "60"}})
+	return st, &restCalls, &wsSessions
+}
+
+func TestBybitStreamMergesBackfillAndUpdates(t *testing.T) {
+	st, _, _ := newBybitStandIn(t, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := make(chan KlineUpdate, 4)
+	go st.Run(ctx, func(upd KlineUpdate) { updates <- upd })
+
+	select {
+	case upd := <-updates:
+		if upd.Symbol != "BTCUSDT" || upd.Timeframe != "60" {
+			t.Fatalf("unexpected key %+v", upd.StreamKey)
+		}
+		if want := []float64{1, 2, 3.5}; !reflect.DeepEqual(Closes(upd.Candles), want) || upd.Candles[2].Confirmed {
+			t.Fatalf("first update = %+v, want closes %v with open last bar", upd.Candles, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("no update received")
+	}
+	select {
+	case upd := <-updates:
+		if want := []float64{2, 3.5, 4}; !reflect.DeepEqual(Closes(upd.Candles), want) || !upd.Candles[2].Confirmed {
+			t.Fatalf("second update = %+v, want closes %v with confirmed last bar", upd.Candles, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("no confirmed update received")
+	}
+}
+
+func TestBybitStreamReconnectsAndBackfills(t *testing.T) {
+	st, restCalls, wsSessions := newBybitStandIn(t, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var received int32
+	go st.Run(ctx, func(KlineUpdate) { atomic.AddInt32(&received, 1) })
+
+	for atomic.LoadInt32(wsSessions) < 2 || atomic.LoadInt32(&received) < 4 {
+		if ctx.Err() != nil {
+			t.Fatalf("sessions=%d updates=%d, want reconnect with fresh updates", atomic.LoadInt32(wsSessions), atomic.LoadInt32(&received))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(restCalls) < 2 {
+		t.Fatalf("REST backfill calls = %d, want one per session", atomic.LoadInt32(restCalls))
+	}
+}
+
+func TestMergeBarKeepsOrder(t *testing.T) {
+	at := func(ms int64) time.Time { return time.UnixMilli(ms) }
+	bars := []Candle{{OpenTime: at(1)}, {OpenTime: at(3)}}
+	bars = mergeBar(bars, Candle{OpenTime: at(2), Close: 2})
+	bars = mergeBar(bars, Candle{OpenTime: at(3), Close: 3})
+	bars = mergeBar(bars, Candle{OpenTime: at(0)})
+	var starts []int64
+	for _, b := range bars {
+		starts = append(starts, b.OpenTime.UnixMilli())
+	}
+	if !reflect.DeepEqual(starts, []int64{0, 1, 2, 3}) || bars[3].Close != 3 {
+		t.Fatalf("mergeBar() = %+v", bars)
+	}
+}
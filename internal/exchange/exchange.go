@@ -1,9 +1,9 @@
-// Package exchange содержит обращение к API бирж: список торговых пар (Bybit linear) и свечи (цены закрытия) для расчёта RSI.
+// Package exchange содержит обращение к API бирж: список торговых пар и свечи для расчёта RSI.
+// Каждая биржа реализует интерфейс Exchange; таймфреймы везде задаются в формате Bybit ("1", "5", "15", "60", "240", "D").
 package exchange
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,108 +13,164 @@ import (
 	"time"
 )
 
-const (
-	bybitInstrumentsPath = "/v5/market/instruments-info?category=linear"
-	bybitKlinePathFmt    = "/v5/market/kline?category=linear&symbol=%s&interval=%s&limit=%d"
-)
+// Exchange — источник рыночных данных по бессрочным фьючерсам одной биржи.
+type Exchange interface {
+	// Name возвращает идентификатор биржи из конфига: bybit, binance или okx.
+	Name() string
+	// DerivativePairs возвращает символы бессрочных контрактов, доступных для торговли.
+	DerivativePairs() ([]string, error)
+	// Candles возвращает свечи в хронологическом порядке (старые → новые); последняя может быть незакрытой.
+	Candles(symbol, timeframe string, limit int) ([]Candle, error)
+	// ServerTime возвращает время сервера биржи.
+	ServerTime() (time.Time, error)
+}
 
-var bybitMainnetHosts = []string{
-	"https://api.bybit.com",
-	"https://api.bytick.com",
-	"https://api.bybit.kz",
-	"https://api.bybit-tr.com",
-	"https://api.bybit.ae",
+// Instrument — сведения о контракте: монеты, тип, шаг цены, статус и дата листинга.
+type Instrument struct {
+	Symbol       string
+	BaseCoin     string
+	QuoteCoin    string
+	ContractType string // LinearPerpetual, LinearFutures …
+	TickSize     float64
+	Status       string    // Trading, PreLaunch, Settling, Delivering, Closed
+	LaunchTime   time.Time // нулевое, если биржа не сообщила
 }
 
-// DerivativePairs возвращает список символов линейных деривативов Bybit (category=linear) в статусе Trading.
-func DerivativePairs() ([]string, error) {
-	body, err := bybitGETAny(bybitInstrumentsPath, 15*time.Second)
-	if err != nil {
-		return nil, err
-	}
+// Trading сообщает, торгуется ли контракт.
+func (in Instrument) Trading() bool {
+	return in.Status == "Trading"
+}
 
-	var data struct {
-		RetCode int    `json:"retCode"`
-		RetMsg  string `json:"retMsg"`
-		Result  struct {
-			List []struct {
-				Symbol string `json:"symbol"`
-				Status string `json:"status"`
-			} `json:"list"`
-		} `json:"result"`
-	}
+// MarketInfo — биржа, которая отдаёт метаданные контрактов и суточный оборот.
+// Нужна фильтрам пар по обороту и возрасту листинга; сейчас её реализует Bybit.
+type MarketInfo interface {
+	// Instruments возвращает контракты биржи в любом статусе.
+	Instruments() ([]Instrument, error)
+	// Turnover24h возвращает оборот за 24 часа в валюте котировки по символам.
+	Turnover24h() (map[string]float64, error)
+}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга ответа Bybit (pairs): %w", err)
-	}
-	if data.RetCode != 0 {
-		return nil, fmt.Errorf("bybit pairs retCode=%d retMsg=%s", data.RetCode, data.RetMsg)
-	}
+// Candle — свеча OHLCV с временем открытия.
+type Candle struct {
+	OpenTime time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64 // объём в базовой монете
+	Turnover float64 // оборот в котируемой монете
+	// Confirmed — свеча закрыта и больше не изменится.
+	Confirmed bool
+}
 
-	var result []string
-	for _, s := range data.Result.List {
-		if s.Status == "Trading" {
-			result = append(result, s.Symbol)
+// parseCandle разбирает строковые поля свечи. Ошибка называет первое некорректное поле,
+// чтобы битая строка ответа биржи не превращалась молча в дыру в ряду.
+func parseCandle(openTimeMs int64, open, high, low, closePrice, volume, turnover string) (Candle, error) {
+	c := Candle{OpenTime: time.UnixMilli(openTimeMs)}
+	fields := []struct {
+		name  string
+		raw   string
+		value *float64
+	}{
+		{"open", open, &c.Open},
+		{"high", high, &c.High},
+		{"low", low, &c.Low},
+		{"close", closePrice, &c.Close},
+		{"volume", volume, &c.Volume},
+		{"turnover", turnover, &c.Turnover},
+	}
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f.raw, 64)
+		if err != nil {
+			return Candle{}, fmt.Errorf("поле %s=%q: %w", f.name, f.raw, err)
 		}
+		*f.value = v
 	}
-	return result, nil
+	return c, nil
 }
 
-// Candles запрашивает свечи с Bybit (linear) и возвращает цены закрытия в хронологическом порядке (старые → новые).
-// symbol — тикер, например BTCUSDT; timeframe — интервал: "5", "15", "60", "240" и т.д.; limit — число свечей.
-func Candles(symbol, timeframe string, limit int) ([]float64, error) {
-	body, err := bybitGETAny(fmt.Sprintf(bybitKlinePathFmt, symbol, timeframe, limit), 10*time.Second)
-	if err != nil {
-		return nil, err
+// Closes возвращает цены закрытия свечей.
+func Closes(candles []Candle) []float64 {
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
 	}
+	return closes
+}
 
-	var data struct {
-		Result struct {
-			List [][]string `json:"list"`
-		} `json:"result"`
+// ClosedCandles отбрасывает незакрытые свечи в конце ряда.
+func ClosedCandles(candles []Candle) []Candle {
+	n := len(candles)
+	for n > 0 && !candles[n-1].Confirmed {
+		n--
 	}
+	return candles[:n]
+}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга ответа Bybit (candles): %w", err)
+// TimeframeDuration возвращает длительность свечи таймфрейма в формате Bybit.
+func TimeframeDuration(timeframe string) time.Duration {
+	switch timeframe {
+	case "D":
+		return 24 * time.Hour
+	case "W":
+		return 7 * 24 * time.Hour
 	}
-
-	var closes []float64
-	for i := len(data.Result.List) - 1; i >= 0; i-- {
-		closePrice, err := strconv.ParseFloat(data.Result.List[i][4], 64)
-		if err != nil {
-			continue
-		}
-		closes = append(closes, closePrice)
+	minutes, err := strconv.Atoi(timeframe)
+	if err != nil || minutes <= 0 {
+		return 0
 	}
+	return time.Duration(minutes) * time.Minute
+}
 
-	return closes, nil
+// closedBy сообщает, закрыта ли свеча с временем открытия openTime к моменту now.
+func closedBy(openTime time.Time, timeframe string, now time.Time) bool {
+	d := TimeframeDuration(timeframe)
+	return d > 0 && !openTime.Add(d).After(now)
 }
 
-// bybitGETAny пробует выполнить запрос к нескольким официальным mainnet-доменам Bybit.
-// Это нужно, потому что некоторые регионы/сети могут получать 403 на api.bybit.com.
-func bybitGETAny(pathAndQuery string, timeout time.Duration) ([]byte, error) {
-	var lastErr error
-	for _, host := range bybitMainnetHosts {
-		url := host + pathAndQuery
-		body, err := bybitGET(url, timeout)
-		if err == nil {
-			return body, nil
-		}
-		lastErr = err
+// Имена поддерживаемых бирж.
+const (
+	Bybit   = "bybit"
+	Binance = "binance"
+	OKX     = "okx"
+)
+
+// Names — биржи, которые можно указать в конфиге.
+var Names = []string{Bybit, Binance, OKX}
+
+// New возвращает адаптер биржи по имени из конфига. Отмена ctx прерывает ожидание
+// лимита запросов, чтобы остановка процесса не ждала конца паузы. У каждого адаптера свой
+// ограничитель запросов и кэш контрактов, поэтому на биржу нужен один вызов на процесс.
+func New(ctx context.Context, name string) (Exchange, error) {
+	switch name {
+	case Bybit, "":
+		b := NewBybit()
+		b.Context = ctx
+		return b, nil
+	case Binance:
+		return NewBinance(), nil
+	case OKX:
+		return NewOKX(), nil
+	default:
+		return nil, fmt.Errorf("неизвестная биржа %q", name)
 	}
-	return nil, fmt.Errorf("не удалось получить ответ Bybit ни с одного домена: %w", lastErr)
 }
 
-// bybitGET выполняет GET-запрос к Bybit c базовыми заголовками и проверкой,
-// что пришёл JSON-ответ с HTTP 200. Если приходит HTML (например, блокировка/ошибка),
-// возвращает понятную ошибку с фрагментом тела.
-func bybitGET(url string, timeout time.Duration) ([]byte, error) {
+// httpGET выполняет GET-запрос c базовыми заголовками и проверкой, что пришёл JSON-ответ с HTTP 200.
+// Если приходит HTML (например, блокировка/ошибка), возвращает понятную ошибку с фрагментом тела.
+func httpGET(name, url string, timeout time.Duration) ([]byte, error) {
+	body, _, err := httpGETWithHeader(name, url, timeout)
+	return body, err
+}
+
+// httpGETWithHeader — httpGET, который возвращает и заголовки ответа.
+func httpGETWithHeader(name, url string, timeout time.Duration) ([]byte, http.Header, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Делаем запрос максимально похожим на успешный curl из Postman.
 	req.Header.Set("Accept", "*/*")
@@ -122,30 +178,31 @@ func bybitGET(url string, timeout time.Duration) ([]byte, error) {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		snippet := strings.TrimSpace(string(body))
-		if len(snippet) > 200 {
-			snippet = snippet[:200]
-		}
-		return nil, fmt.Errorf("bybit http %d: %s", resp.StatusCode, snippet)
+		return nil, nil, fmt.Errorf("%s http %d: %s", name, resp.StatusCode, snippet(body))
 	}
 	trimmed := strings.TrimSpace(string(body))
 	if trimmed == "" {
-		return nil, errors.New("пустой ответ bybit")
+		return nil, nil, errors.New("пустой ответ " + name)
 	}
 	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
-		if len(trimmed) > 200 {
-			trimmed = trimmed[:200]
-		}
-		return nil, fmt.Errorf("ожидался json от bybit, получено: %s", trimmed)
+		return nil, nil, fmt.Errorf("ожидался json от %s, получено: %s", name, snippet(body))
+	}
+	return body, resp.Header, nil
+}
+
+func snippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) > 200 {
+		s = s[:200]
 	}
-	return body, nil
+	return s
 }
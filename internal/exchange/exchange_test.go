@@ -0,0 +1,226 @@
+package exchange
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newJSONServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBybitCandlesReversesOrder(t *testing.T) {
+	srv := newJSONServer(t, map[string]string{
+		"/v5/market/kline": `{"retCode":0,"result":{"list":[["3","30","31","29","30","5","150"],["2","20","21","19","20","5","100"],["1","10","11","9","10","5","50"]]}}`,
+	})
+	ex := &BybitExchange{Hosts: []string{srv.URL}}
+
+	candles, err := ex.Candles("BTCUSDT", "60", 3)
+	if err != nil {
+		t.Fatalf("Candles() error: %v", err)
+	}
+	if want := []float64{10, 20, 30}; !reflect.DeepEqual(Closes(candles), want) {
+		t.Fatalf("Candles() = %v, want closes %v", candles, want)
+	}
+	want := Candle{OpenTime: candles[0].OpenTime, Open: 10, High: 11, Low: 9, Close: 10, Volume: 5, Turnover: 50, Confirmed: true}
+	if candles[0].OpenTime.UnixMilli() != 1 || candles[0] != want || !candles[2].Confirmed {
+		t.Fatalf("Candles() = %+v, want first candle %+v", candles, want)
+	}
+}
+
+func TestBybitCandlesRejectsMalformedRows(t *testing.T) {
+	srv := newJSONServer(t, map[string]string{
+		"/v5/market/kline": `{"retCode":0,"result":{"list":[["2","20","21","19","oops","5","100"],["1","10","11","9","10","5","50"]]}}`,
+	})
+	ex := &BybitExchange{Hosts: []string{srv.URL}}
+
+	if _, err := ex.Candles("BTCUSDT", "60", 2); err == nil || !strings.Contains(err.Error(), "close") {
+		t.Fatalf("Candles() error = %v, want malformed close field", err)
+	}
+}
+
+func TestBybitInstrumentsFollowsCursor(t *testing.T) {
+	pages := map[string]string{
+		"": `{"retCode":0,"result":{"list":[
+			{"symbol":"BTCUSDT","status":"Trading","contractType":"LinearPerpetual","baseCoin":"BTC","quoteCoin":"USDT","launchTime":"1584230400000","priceFilter":{"tickSize":"0.10"}},
+			{"symbol":"OLDUSDT","status":"Closed","contractType":"LinearPerpetual","baseCoin":"OLD","quoteCoin":"USDT","launchTime":"1584230400000","priceFilter":{"tickSize":"0.001"}}],
+			"nextPageCursor":"page=2"}}`,
+		"page=2": `{"retCode":0,"result":{"list":[
+			{"symbol":"BTCPERP","status":"Trading","contractType":"LinearPerpetual","baseCoin":"BTC","quoteCoin":"USDC","launchTime":"0","priceFilter":{"tickSize":"0.5"}}],
+			"nextPageCursor":""}}`,
+	}
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v5/market/tickers" {
+			fmt.Fprint(w, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","turnover24h":"1234567.5"},{"symbol":"BTCPERP","turnover24h":"10"}]}}`)
+			return
+		}
+		requests++
+		fmt.Fprint(w, pages[r.URL.Query().Get("cursor")])
+	}))
+	t.Cleanup(srv.Close)
+	ex := &BybitExchange{Hosts: []string{srv.URL}, InstrumentsRefresh: time.Hour}
+
+	instruments, err := ex.Instruments()
+	if err != nil {
+		t.Fatalf("Instruments() error: %v", err)
+	}
+	want := []Instrument{
+		{Symbol: "BTCUSDT", BaseCoin: "BTC", QuoteCoin: "USDT", ContractType: "LinearPerpetual", TickSize: 0.1, Status: "Trading", LaunchTime: time.UnixMilli(1584230400000)},
+		{Symbol: "OLDUSDT", BaseCoin: "OLD", QuoteCoin: "USDT", ContractType: "LinearPerpetual", TickSize: 0.001, Status: "Closed", LaunchTime: time.UnixMilli(1584230400000)},
+		{Symbol: "BTCPERP", BaseCoin: "BTC", QuoteCoin: "USDC", ContractType: "LinearPerpetual", TickSize: 0.5, Status: "Trading"},
+	}
+	if !reflect.DeepEqual(instruments, want) {
+		t.Fatalf("Instruments() = %+v, want %+v", instruments, want)
+	}
+
+	pairs, err := ex.DerivativePairs()
+	if err != nil || !reflect.DeepEqual(pairs, []string{"BTCUSDT", "BTCPERP"}) {
+		t.Fatalf("DerivativePairs() = %v, %v, want trading symbols from both pages", pairs, err)
+	}
+	if requests != 2 {
+		t.Fatalf("instruments-info requested %d times, want 2 pages and a cache hit", requests)
+	}
+
+	turnover, err := ex.Turnover24h()
+	if err != nil {
+		t.Fatalf("Turnover24h() error: %v", err)
+	}
+	if turnover["BTCUSDT"] != 1234567.5 || turnover["BTCPERP"] != 10 {
+		t.Fatalf("Turnover24h() = %v", turnover)
+	}
+}
+
+func TestBybitInstrumentsSharedBetweenCallers(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","status":"Trading","contractType":"LinearPerpetual","baseCoin":"BTC","quoteCoin":"USDT"}],"nextPageCursor":""}}`)
+	}))
+	t.Cleanup(srv.Close)
+	ex := &BybitExchange{Hosts: []string{srv.URL}, InstrumentsRefresh: time.Hour}
+
+	// Так адаптер делят сканер, команды и трекеры нескольких ботов одной биржи.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if pairs, err := ex.DerivativePairs(); err != nil || len(pairs) != 1 {
+				t.Errorf("DerivativePairs() = %v, %v", pairs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("instruments-info requested %d times by 8 callers, want 1", n)
+	}
+}
+
+func TestBybitCandlesRangePaginates(t *testing.T) {
+	// Стенд отдаёт не больше двух самых новых свечей из [start, end], как Bybit при limit=2.
+	hour := time.Hour.Milliseconds()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		var rows []string
+		for ts := end / hour * hour; ts >= start && len(rows) < 2; ts -= hour {
+			price := ts / hour
+			rows = append(rows, fmt.Sprintf(`["%d","%d","%d","%d","%d","1","1"]`, ts, price, price, price, price))
+		}
+		fmt.Fprintf(w, `{"retCode":0,"result":{"list":[%s]}}`, strings.Join(rows, ","))
+	}))
+	t.Cleanup(srv.Close)
+	ex := &BybitExchange{Hosts: []string{srv.URL}}
+
+	start := time.UnixMilli(10 * hour)
+	candles, err := ex.CandlesRange("BTCUSDT", "60", start, start.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("CandlesRange() error: %v", err)
+	}
+	if want := []float64{10, 11, 12, 13, 14}; !reflect.DeepEqual(Closes(candles), want) {
+		t.Fatalf("CandlesRange() closes = %v, want %v", Closes(candles), want)
+	}
+}
+
+func TestBinanceAdapter(t *testing.T) {
+	srv := newJSONServer(t, map[string]string{
+		"/fapi/v1/exchangeInfo": `{"symbols":[
+			{"symbol":"BTCUSDT","status":"TRADING","contractType":"PERPETUAL"},
+			{"symbol":"BTCUSDT_250926","status":"TRADING","contractType":"CURRENT_QUARTER"},
+			{"symbol":"OLDUSDT","status":"SETTLING","contractType":"PERPETUAL"}]}`,
+		"/fapi/v1/klines": `[[1,"10","11","9","10","3",2,"30"],[3,"20","21","19","20","3",4,"60"]]`,
+		"/fapi/v1/time":   `{"serverTime":1700000000000}`,
+	})
+	ex := &BinanceExchange{Host: srv.URL}
+
+	pairs, err := ex.DerivativePairs()
+	if err != nil || !reflect.DeepEqual(pairs, []string{"BTCUSDT"}) {
+		t.Fatalf("DerivativePairs() = %v, %v", pairs, err)
+	}
+	candles, err := ex.Candles("BTCUSDT", "240", 2)
+	if err != nil || !reflect.DeepEqual(Closes(candles), []float64{10, 20}) {
+		t.Fatalf("Candles() = %v, %v", candles, err)
+	}
+	if _, err := ex.Candles("BTCUSDT", "7", 2); err == nil {
+		t.Fatal("Candles() with unsupported timeframe should fail")
+	}
+	ts, err := ex.ServerTime()
+	if err != nil || ts.UnixMilli() != 1700000000000 {
+		t.Fatalf("ServerTime() = %v, %v", ts, err)
+	}
+}
+
+func TestOKXServerTimeEmptyResponse(t *testing.T) {
+	srv := newJSONServer(t, map[string]string{
+		"/api/v5/public/time": `{"code":"0","msg":"","data":[]}`,
+	})
+	ex := &OKXExchange{Host: srv.URL}
+	if _, err := ex.ServerTime(); err == nil || !strings.Contains(err.Error(), "пустой ответ") {
+		t.Fatalf("ServerTime() error = %v, want empty response", err)
+	}
+}
+
+func TestOKXAdapter(t *testing.T) {
+	srv := newJSONServer(t, map[string]string{
+		"/api/v5/public/instruments": `{"code":"0","msg":"","data":[{"instId":"BTC-USDT-SWAP","state":"live"},{"instId":"X-USDT-SWAP","state":"suspend"}]}`,
+		"/api/v5/market/candles":     `{"code":"0","msg":"","data":[["2","20","21","19","20","30","3","60","0"],["1","10","11","9","10","30","3","30","1"]]}`,
+		"/api/v5/public/time":        `{"code":"0","msg":"","data":[{"ts":"1700000000000"}]}`,
+	})
+	ex := &OKXExchange{Host: srv.URL}
+
+	pairs, err := ex.DerivativePairs()
+	if err != nil || !reflect.DeepEqual(pairs, []string{"BTC-USDT-SWAP"}) {
+		t.Fatalf("DerivativePairs() = %v, %v", pairs, err)
+	}
+	candles, err := ex.Candles("BTC-USDT-SWAP", "D", 2)
+	if err != nil || !reflect.DeepEqual(Closes(candles), []float64{10, 20}) {
+		t.Fatalf("Candles() = %v, %v", candles, err)
+	}
+	if !candles[0].Confirmed || candles[1].Confirmed {
+		t.Fatalf("Candles() confirm flags = %+v", candles)
+	}
+	ts, err := ex.ServerTime()
+	if err != nil || ts.UnixMilli() != 1700000000000 {
+		t.Fatalf("ServerTime() = %v, %v", ts, err)
+	}
+}
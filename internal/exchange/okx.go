@@ -0,0 +1,149 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	okxHost            = "https://www.okx.com"
+	okxInstrumentsPath = "/api/v5/public/instruments?instType=SWAP"
+	okxCandlesPathFmt  = "/api/v5/market/candles?instId=%s&bar=%s&limit=%d"
+	okxTimePath        = "/api/v5/public/time"
+	okxMaxCandleLimit  = 300
+)
+
+var okxBars = map[string]string{
+	"1":   "1m",
+	"5":   "5m",
+	"15":  "15m",
+	"60":  "1H",
+	"240": "4H",
+	"D":   "1Dutc",
+}
+
+// OKXExchange — бессрочные свопы OKX (instType=SWAP). Символы в формате OKX, например BTC-USDT-SWAP.
+type OKXExchange struct {
+	// Host — базовый адрес API.
+	Host string
+}
+
+// NewOKX создаёт адаптер OKX perpetual swaps.
+func NewOKX() *OKXExchange {
+	return &OKXExchange{Host: okxHost}
+}
+
+// Name возвращает "okx".
+func (o *OKXExchange) Name() string {
+	return OKX
+}
+
+// okxResponse — общая обёртка ответов OKX v5.
+type okxResponse struct {
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (o *OKXExchange) get(pathAndQuery, what string, timeout time.Duration) (json.RawMessage, error) {
+	body, err := httpGET("okx", o.Host+pathAndQuery, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var resp okxResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа OKX (%s): %w", what, err)
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx %s code=%s msg=%s", what, resp.Code, resp.Msg)
+	}
+	return resp.Data, nil
+}
+
+// DerivativePairs возвращает бессрочные свопы OKX в состоянии live.
+func (o *OKXExchange) DerivativePairs() ([]string, error) {
+	data, err := o.get(okxInstrumentsPath, "pairs", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		InstID string `json:"instId"`
+		State  string `json:"state"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа OKX (pairs): %w", err)
+	}
+
+	var result []string
+	for _, s := range list {
+		if s.State == "live" {
+			result = append(result, s.InstID)
+		}
+	}
+	return result, nil
+}
+
+// Candles запрашивает свечи OKX и возвращает их в хронологическом порядке (старые → новые).
+// Закрытость свечи OKX сообщает сама (поле confirm).
+func (o *OKXExchange) Candles(symbol, timeframe string, limit int) ([]Candle, error) {
+	bar, ok := okxBars[timeframe]
+	if !ok {
+		return nil, fmt.Errorf("okx: неподдерживаемый таймфрейм %q", timeframe)
+	}
+	if limit > okxMaxCandleLimit {
+		limit = okxMaxCandleLimit
+	}
+	data, err := o.get(fmt.Sprintf(okxCandlesPathFmt, symbol, bar, limit), "candles", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа OKX (candles): %w", err)
+	}
+
+	candles := make([]Candle, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		// [ts, o, h, l, c, vol (контракты), volCcy (база), volCcyQuote (котировка), confirm]
+		row := rows[i]
+		if len(row) < 9 {
+			return nil, fmt.Errorf("okx candles %s: строка %d: ожидалось 9 полей, получено %d", symbol, i, len(row))
+		}
+		ts, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("okx candles %s: строка %d: поле ts=%q: %w", symbol, i, row[0], err)
+		}
+		c, err := parseCandle(ts, row[1], row[2], row[3], row[4], row[6], row[7])
+		if err != nil {
+			return nil, fmt.Errorf("okx candles %s: строка %d: %w", symbol, i, err)
+		}
+		c.Confirmed = row[8] == "1"
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
+
+// ServerTime возвращает время сервера OKX.
+func (o *OKXExchange) ServerTime() (time.Time, error) {
+	data, err := o.get(okxTimePath, "time", 10*time.Second)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var list []struct {
+		TS string `json:"ts"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return time.Time{}, fmt.Errorf("ошибка парсинга ответа OKX (time): %w", err)
+	}
+	if len(list) == 0 {
+		return time.Time{}, errors.New("пустой ответ OKX (time)")
+	}
+	ms, err := strconv.ParseInt(list[0].TS, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("okx time: %w", err)
+	}
+	return time.UnixMilli(ms), nil
+}
@@ -0,0 +1,15 @@
+// Package fileutil содержит общие операции с файлами состояния бота.
+package fileutil
+
+import "os"
+
+// WriteAtomic записывает data в path через временный файл рядом с ним и переименование,
+// чтобы остановка посреди записи не оставила обрезанный файл: на месте path всегда
+// лежит либо старое, либо новое содержимое целиком.
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
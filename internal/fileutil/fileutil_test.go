@@ -0,0 +1,23 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomicReplacesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, body := range []string{`{"a":1}`, `{"b":2}`} {
+		if err := WriteAtomic(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != body {
+			t.Fatalf("ReadFile() = %q, %v, want %q", data, err, body)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+}
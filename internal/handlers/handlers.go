@@ -7,30 +7,35 @@ import (
 	"strings"
 
 	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/delivery"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/leaderboard"
+	"grevtsevalex/crypto-bot/internal/outcomes"
+	"grevtsevalex/crypto-bot/internal/subscribers"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type Handler struct {
-	bot            *tgbotapi.BotAPI
-	signalMode     string
-	getSubscribers func() map[int64]bool
-	subscribe      func(chatID int64)
-	unsubscribe    func(chatID int64)
+	bot      *tgbotapi.BotAPI
+	queue    *delivery.Queue // ответы уходят через общую с сигналами очередь отправки
+	cfg      *config.Store
+	subs     *subscribers.Store
+	outcomes *outcomes.Tracker
+	ex       exchange.Exchange
+	pairs    pairsCache
+	board    *leaderboard.Board
 }
 
-func New(
-	bot *tgbotapi.BotAPI,
-	signalMode string,
-	getSubscribers func() map[int64]bool,
-	subscribe, unsubscribe func(chatID int64),
-) *Handler {
+func New(bot *tgbotapi.BotAPI, queue *delivery.Queue, cfg *config.Store, subs *subscribers.Store, tracker *outcomes.Tracker, ex exchange.Exchange, board *leaderboard.Board) *Handler {
 	return &Handler{
-		bot:            bot,
-		signalMode:     signalMode,
-		getSubscribers: getSubscribers,
-		subscribe:      subscribe,
-		unsubscribe:    unsubscribe,
+		bot:      bot,
+		queue:    queue,
+		cfg:      cfg,
+		subs:     subs,
+		outcomes: tracker,
+		ex:       ex,
+		board:    board,
 	}
 }
 
@@ -58,63 +63,77 @@ func (h *Handler) HandleUpdates() {
 				h.checkSubscriptionStatus(chatID)
 			case "settings":
 				h.showSettingsOverview(chatID)
+			case "stats":
+				h.showStats(chatID, update.Message.CommandArguments())
+			case "watch", "unwatch", "ignore":
+				go h.handleSymbolCommand(chatID, update.Message.Command(), update.Message.CommandArguments())
+			case "top":
+				h.showTop(chatID, update.Message.CommandArguments())
+			case "rsi":
+				go h.showIndicators(chatID, update.Message.CommandArguments(), false)
 			case "help":
 				h.showHelp(chatID)
 			}
+			continue
+		}
+		// В личном чате тикер обычным сообщением работает как /rsi.
+		if update.Message.Chat.IsPrivate() && looksLikeSymbol(update.Message.Text) {
+			go h.showIndicators(chatID, update.Message.Text, true)
 		}
 	}
 }
 
 func (h *Handler) showMainMenu(chatID int64) {
-	rows := [][]tgbotapi.InlineKeyboardButton{
+	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("✅ Подписаться", "subscribe"),
 			tgbotapi.NewInlineKeyboardButtonData("❌ Отписаться", "unsubscribe"),
 		),
-	}
-	if config.Get().LockTimeframe {
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📊 Статус подписки", "status"),
-		))
-	} else {
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📊 Статус подписки", "status"),
 			tgbotapi.NewInlineKeyboardButtonData("⚙️ Настройки", "settings"),
-		))
-	}
-	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		),
+	)
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🤖 *%s*\n\n%s\nВыберите действие:", h.botTitle(), h.botDescription()))
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = kb
-	if _, err := h.bot.Send(msg); err != nil {
-		log.Printf("Ошибка отправки меню %d: %v", chatID, err)
-	}
+	h.send(chatID, msg)
 }
 
 func (h *Handler) showSettingsOverview(chatID int64) {
-	cfg := config.Get()
-	if cfg.LockTimeframe {
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚙️ Таймфрейм зафиксирован: *%s*", humanTimeframe(cfg.Timeframe)))
-		msg.ParseMode = "Markdown"
-		h.bot.Send(msg)
+	profile, ok := h.subs.Get(chatID)
+	if !ok {
+		h.sendWithMenu(chatID, "⚠️ Настройки сигналов доступны после подписки.")
 		return
 	}
+	cfg := h.cfg.Get()
 	text := fmt.Sprintf(
 		"⚙️ *Настройки*\n\n"+
-			"Таймфрейм: *%s*\n"+
+			"%s\n\n"+
+			"*Индикаторы бота:*\n%s\n\n"+
 			"Выберите, что изменить:",
-		cfg.Timeframe,
+		describeProfile(profile), describeIndicators(cfg),
 	)
-	kb := tgbotapi.NewInlineKeyboardMarkup(
+	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🕯 Таймфрейм", "menu_timeframe"),
+			tgbotapi.NewInlineKeyboardButtonData("🎯 Режим", "menu_mode"),
+			tgbotapi.NewInlineKeyboardButtonData("⭐ Символы", "menu_symbols"),
 		),
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Главное меню", "main_menu")),
-	)
+	}
+	if !cfg.LockTimeframe {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🕯 Таймфреймы", "menu_timeframe"),
+				tgbotapi.NewInlineKeyboardButtonData("🎚 Пороги", "menu_thresholds"),
+			),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📐 Индикаторы", "menu_indicators")),
+		)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Главное меню", "main_menu")))
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
-	msg.ReplyMarkup = kb
-	h.bot.Send(msg)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.send(chatID, msg)
 }
 
 func (h *Handler) sendSubmenu(chatID int64, title string, options [][]string, back string) {
@@ -130,7 +149,7 @@ func (h *Handler) sendSubmenu(chatID int64, title string, options [][]string, ba
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", back)))
 	msg := tgbotapi.NewMessage(chatID, title)
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
-	h.bot.Send(msg)
+	h.send(chatID, msg)
 }
 
 func (h *Handler) handleCallback(query *tgbotapi.CallbackQuery) {
@@ -146,22 +165,23 @@ func (h *Handler) handleCallback(query *tgbotapi.CallbackQuery) {
 		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 		return
 	case "subscribe":
-		subs := h.getSubscribers()
-		if _, exists := subs[chatID]; exists {
-			responseText = "⚠️ Вы уже подписаны на сигналы!"
-		} else {
-			h.subscribe(chatID)
+		added, err := h.subs.Subscribe(chatID)
+		if err != nil {
+			log.Printf("Ошибка сохранения подписчиков: %v", err)
+		}
+		if added {
 			responseText = "✅ Вы подписаны на уведомления."
+		} else {
+			responseText = "⚠️ Вы уже подписаны на сигналы!"
 		}
 		showKeyboard = true
 	case "unsubscribe":
-		h.unsubscribe(chatID)
+		h.removeSubscriber(chatID)
 		responseText = "❌ Вы отписались от сигналов."
 		showKeyboard = true
 	case "status":
-		subs := h.getSubscribers()
-		if _, exists := subs[chatID]; exists {
-			responseText = "✅ Подписан."
+		if profile, exists := h.subs.Get(chatID); exists {
+			responseText = "✅ Подписан.\n\n" + describeProfile(profile)
 		} else {
 			responseText = "❌ Не подписан."
 		}
@@ -170,28 +190,87 @@ func (h *Handler) handleCallback(query *tgbotapi.CallbackQuery) {
 		h.showSettingsOverview(chatID)
 		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 		return
+	case "menu_mode":
+		h.sendSubmenu(chatID, "Режим сигналов:", [][]string{
+			{"🔴 Upper", "mode_upper"}, {"🟢 Lower", "mode_lower"}, {"🔁 Оба", "mode_both"},
+		}, "settings")
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	case "menu_symbols":
+		h.handleSymbolCommand(chatID, "watch", "")
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	case "mode_upper", "mode_lower", "mode_both":
+		value := strings.TrimPrefix(data, "mode_")
+		responseText = h.updateProfile(chatID, func(p *subscribers.Profile) { p.Mode = value }, fmt.Sprintf("✅ Режим: %s", humanMode(value)))
+		showKeyboard = true
 	case "menu_timeframe":
-		if config.Get().LockTimeframe {
+		if h.cfg.Get().LockTimeframe {
 			responseText = "⚠️ Таймфрейм зафиксирован в конфиге бота."
 			showKeyboard = true
 			break
 		}
-		h.sendSubmenu(chatID, "Таймфрейм свечей:", [][]string{
-			{"1m", "timeframe_1"}, {"5m", "timeframe_5"}, {"15m", "timeframe_15"}, {"1h", "timeframe_60"}, {"4h", "timeframe_240"}, {"1D", "timeframe_D"},
-		}, "settings")
+		profile, _ := h.subs.Get(chatID)
+		var options [][]string
+		for _, tf := range config.Timeframes {
+			label := humanTimeframe(tf)
+			if profile.HasTimeframe(tf) {
+				label = "✅ " + label
+			}
+			options = append(options, []string{label, "timeframe_" + tf})
+		}
+		h.sendSubmenu(chatID, "Таймфреймы свечей (нажмите, чтобы включить или выключить):", options, "settings")
 		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 		return
 	case "timeframe_1", "timeframe_5", "timeframe_15", "timeframe_60", "timeframe_240", "timeframe_D":
-		if config.Get().LockTimeframe {
+		if h.cfg.Get().LockTimeframe {
 			responseText = "⚠️ Таймфрейм зафиксирован в конфиге бота."
 			showKeyboard = true
 			break
 		}
 		value := strings.TrimPrefix(data, "timeframe_")
-		_ = config.Update(func(c *config.Config) { c.Timeframe = value })
-		responseText = fmt.Sprintf("✅ Таймфрейм: %s", humanTimeframe(value))
+		var timeframes []string
+		responseText = h.updateProfile(chatID, func(p *subscribers.Profile) {
+			p.Timeframes = toggleTimeframe(p.Timeframes, value)
+			timeframes = p.Timeframes
+		}, "")
+		if responseText == "" {
+			responseText = fmt.Sprintf("✅ Таймфреймы: %s", humanTimeframes(timeframes))
+		}
 		showKeyboard = true
+	case "menu_indicators":
+		if h.cfg.Get().LockTimeframe {
+			responseText = "⚠️ Параметры зафиксированы в конфиге бота."
+			showKeyboard = true
+			break
+		}
+		h.sendParamsMenu(chatID, "Параметры расчёта (общие для всех подписчиков бота):", indicatorParams)
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	case "menu_thresholds":
+		if h.cfg.Get().LockTimeframe {
+			responseText = "⚠️ Параметры зафиксированы в конфиге бота."
+			showKeyboard = true
+			break
+		}
+		h.sendParamsMenu(chatID, "Пороги вашего профиля:", thresholdParams)
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
 	default:
+		if strings.HasPrefix(data, "top_") {
+			h.handleTopCallback(query)
+			return
+		}
+		if strings.HasPrefix(data, "unwatch_") || strings.HasPrefix(data, "unignore_") {
+			h.handleSymbolCallback(chatID, data)
+			h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+			return
+		}
+		if strings.HasPrefix(data, "param_") || strings.HasPrefix(data, "set_") {
+			responseText = h.handleParamCallback(chatID, data)
+			showKeyboard = responseText != ""
+			break
+		}
 		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 		return
 	}
@@ -204,60 +283,121 @@ func (h *Handler) handleCallback(query *tgbotapi.CallbackQuery) {
 				tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Главное меню", "main_menu")),
 			)
 		}
-		h.bot.Send(msg)
+		h.send(chatID, msg)
 	}
 	h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
 }
 
 func (h *Handler) unsubscribeUser(chatID int64) {
-	h.unsubscribe(chatID)
-	h.bot.Send(tgbotapi.NewMessage(chatID, "❌ Вы отписались от сигналов"))
+	h.removeSubscriber(chatID)
+	h.send(chatID, tgbotapi.NewMessage(chatID, "❌ Вы отписались от сигналов"))
+}
+
+func (h *Handler) removeSubscriber(chatID int64) {
+	removed, err := h.subs.Unsubscribe(chatID)
+	if err != nil {
+		log.Printf("Ошибка сохранения подписчиков: %v", err)
+	}
+	if removed {
+		log.Printf("Пользователь отписался: %d", chatID)
+	}
+}
+
+// updateProfile применяет изменение к профилю чата и возвращает текст ответа.
+func (h *Handler) updateProfile(chatID int64, updater func(*subscribers.Profile), okText string) string {
+	updated, err := h.subs.Update(chatID, updater)
+	if err != nil {
+		log.Printf("Ошибка сохранения подписчиков: %v", err)
+	}
+	if !updated {
+		return "⚠️ Сначала подпишитесь на сигналы."
+	}
+	return okText
+}
+
+// send ставит сообщение в очередь отправки бота и не ждёт его доставки: ответы на команды
+// подчиняются тем же лимитам Telegram, что и сигналы.
+func (h *Handler) send(chatID int64, msg tgbotapi.Chattable) {
+	h.queue.Enqueue(chatID, msg, func(_ tgbotapi.Message, err error) {
+		if err != nil {
+			log.Printf("Ошибка отправки сообщения %d: %v", chatID, err)
+		}
+	})
+}
+
+// escapeMarkdown экранирует ввод пользователя перед вставкой в сообщение с разметкой Markdown:
+// иначе символ вроде _ или * ломает разбор, и Telegram отклоняет весь ответ.
+func escapeMarkdown(s string) string {
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, s)
+}
+
+func (h *Handler) sendWithMenu(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Главное меню", "main_menu")),
+	)
+	h.send(chatID, msg)
 }
 
 func (h *Handler) checkSubscriptionStatus(chatID int64) {
-	subs := h.getSubscribers()
 	status := "❌ Не подписан"
-	if _, exists := subs[chatID]; exists {
-		status = "✅ Подписан"
+	if profile, exists := h.subs.Get(chatID); exists {
+		status = "✅ Подписан\n\n" + describeProfile(profile)
 	}
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📊 *Статус подписки*\n\n%s", status))
 	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
+	h.send(chatID, msg)
 }
 
 func (h *Handler) showHelp(chatID int64) {
-	cfg := config.Get()
+	cfg := h.cfg.Get()
 	helpText := fmt.Sprintf(`🤖 *%s*
 
 *Команды:*
 /start — главное меню
 /settings — настройки
 /status — статус подписки
+/stats [дней] — результативность сигналов бота
+/rsi SYMBOL [таймфрейм] — RSI и Stoch RSI символа сейчас
+/top [таймфрейм] — пары с самым высоким и низким RSI
+/watch SYMBOL… — сигналы только по этим символам
+/unwatch SYMBOL… | all — убрать символы из списка
+/ignore SYMBOL… — никогда не присылать сигналы по символам
 /stop — отписаться
 /help — эта справка
 
 *Текущие параметры:*
-Таймфрейм: *%s*
+Таймфрейм по умолчанию: *%s*
+%s
+Пороги по умолчанию: RSI ≥ %.2f / ≤ %.2f, %%K ≥ %.2f / ≤ %.2f
 
-Расчёт индикаторов зафиксирован на канонических значениях Bybit/TradingView. %s`,
-		h.botTitle(), humanTimeframe(cfg.Timeframe), h.botDescription())
+У каждого подписчика свой профиль: режим (upper/lower/оба), набор таймфреймов и пороги RSI/Stoch RSI. Изменить их можно в /settings. %s`,
+		h.botTitle(), humanTimeframe(cfg.Timeframe), describeIndicators(cfg),
+		cfg.RSIUpper, cfg.RSILower, cfg.StochUpper, cfg.StochLower, h.botDescription())
 	msg := tgbotapi.NewMessage(chatID, helpText)
 	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
+	h.send(chatID, msg)
 }
 
 func (h *Handler) botTitle() string {
-	tf := humanTimeframe(config.Get().Timeframe)
-	if h.signalMode == "lower" {
+	tf := humanTimeframe(h.cfg.Get().Timeframe)
+	switch h.cfg.Get().SignalMode {
+	case subscribers.ModeLower:
 		return fmt.Sprintf("Бот Lower RSI/Stoch RSI %s", tf)
+	case subscribers.ModeBoth:
+		return fmt.Sprintf("Бот RSI/Stoch RSI %s", tf)
 	}
 	return fmt.Sprintf("Бот Upper RSI/Stoch RSI %s", tf)
 }
 
 func (h *Handler) botDescription() string {
-	tf := humanTimeframe(config.Get().Timeframe)
-	if h.signalMode == "lower" {
+	tf := humanTimeframe(h.cfg.Get().Timeframe)
+	switch h.cfg.Get().SignalMode {
+	case subscribers.ModeLower:
 		return fmt.Sprintf("Уведомление только по нижней зоне RSI и Stoch RSI (%%K около 0). Таймфрейм: %s.", tf)
+	case subscribers.ModeBoth:
+		return fmt.Sprintf("Уведомления по верхней и нижней зонам RSI и Stoch RSI. Таймфрейм по умолчанию: %s.", tf)
 	}
 	return fmt.Sprintf("Уведомление только по верхней зоне RSI и Stoch RSI. Таймфрейм: %s.", tf)
 }
@@ -280,3 +420,70 @@ func humanTimeframe(value string) string {
 		return value
 	}
 }
+
+func humanTimeframes(values []string) string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, humanTimeframe(v))
+	}
+	return strings.Join(out, ", ")
+}
+
+func humanMode(mode string) string {
+	switch mode {
+	case subscribers.ModeLower:
+		return "Lower"
+	case subscribers.ModeBoth:
+		return "Upper + Lower"
+	default:
+		return "Upper"
+	}
+}
+
+// toggleTimeframe включает или выключает таймфрейм; последний таймфрейм выключить нельзя.
+func toggleTimeframe(timeframes []string, value string) []string {
+	var out []string
+	found := false
+	for _, tf := range timeframes {
+		if tf == value {
+			found = true
+			continue
+		}
+		out = append(out, tf)
+	}
+	if !found {
+		for _, tf := range config.Timeframes {
+			if tf == value || containsString(timeframes, tf) {
+				out = append(out, tf)
+			}
+		}
+		return out
+	}
+	if len(out) == 0 {
+		return timeframes
+	}
+	return out
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func describeProfile(p subscribers.Profile) string {
+	text := fmt.Sprintf(
+		"Режим: *%s*\nТаймфреймы: *%s*\nUpper: RSI ≥ %.2f и %%K ≥ %.2f\nLower: RSI ≤ %.2f и %%K ≤ %.2f",
+		humanMode(p.Mode), humanTimeframes(p.Timeframes), p.RSIUpper, p.StochUpper, p.RSILower, p.StochLower,
+	)
+	if len(p.Symbols) > 0 {
+		text += fmt.Sprintf("\nТолько символы: `%s`", strings.Join(p.Symbols, ", "))
+	}
+	if len(p.ExcludeSymbols) > 0 {
+		text += fmt.Sprintf("\nИсключены: `%s`", strings.Join(p.ExcludeSymbols, ", "))
+	}
+	return text
+}
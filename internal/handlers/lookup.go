@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/rules"
+)
+
+// pairsTTL — как долго кэшируется список пар биржи для поиска символов.
+const pairsTTL = 10 * time.Minute
+
+// maxSuggestions — сколько похожих символов предложить, если запрос неоднозначен.
+const maxSuggestions = 5
+
+// pairsCache — список пар биржи, который нужен для поиска символа по запросу пользователя.
+type pairsCache struct {
+	mu      sync.Mutex
+	pairs   []string
+	fetched time.Time
+}
+
+func (c *pairsCache) get(ex exchange.Exchange) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pairs != nil && time.Since(c.fetched) < pairsTTL {
+		return c.pairs, nil
+	}
+	pairs, err := ex.DerivativePairs()
+	if err != nil {
+		if c.pairs != nil {
+			return c.pairs, nil // лучше устаревший список, чем отказ
+		}
+		return nil, err
+	}
+	c.pairs, c.fetched = pairs, time.Now()
+	return pairs, nil
+}
+
+// showIndicators отвечает на /rsi SYMBOL [таймфрейм] текущими RSI и Stoch RSI символа.
+// quiet — запрос пришёл обычным текстом: если символ не найден, бот промолчит.
+func (h *Handler) showIndicators(chatID int64, args string, quiet bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		h.sendWithMenu(chatID, "⚠️ Формат: /rsi BTCUSDT или /rsi BTC 4h")
+		return
+	}
+	timeframes := config.Timeframes
+	if len(fields) == 2 {
+		tf, ok := parseTimeframe(fields[1])
+		if !ok {
+			h.sendWithMenu(chatID, fmt.Sprintf("⚠️ Неизвестный таймфрейм «%s». Доступны: %s.", escapeMarkdown(fields[1]), humanTimeframes(config.Timeframes)))
+			return
+		}
+		timeframes = []string{tf}
+	}
+
+	pairs, err := h.pairs.get(h.ex)
+	if err != nil {
+		log.Printf("Ошибка загрузки пар для /rsi: %v", err)
+		if !quiet {
+			h.sendWithMenu(chatID, "⚠️ Не удалось получить список пар с биржи, попробуйте позже.")
+		}
+		return
+	}
+	// Обычный текст принимаем только как точный тикер, чтобы не отвечать на случайные слова.
+	symbol, suggestions := matchSymbol(fields[0], pairs, !quiet)
+	if symbol == "" {
+		if quiet {
+			return
+		}
+		text := fmt.Sprintf("⚠️ Символ «%s» не найден на бирже.", escapeMarkdown(fields[0]))
+		if len(suggestions) > 0 {
+			text += fmt.Sprintf("\nВозможно, вы имели в виду: `%s`", strings.Join(suggestions, "`, `"))
+		}
+		h.sendWithMenu(chatID, text)
+		return
+	}
+
+	cfg := h.cfg.Get()
+	rows := make([]indicatorRow, len(timeframes))
+	var wg sync.WaitGroup
+	for i, tf := range timeframes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows[i] = h.lookupIndicators(cfg, chatID, symbol, tf)
+		}()
+	}
+	wg.Wait()
+	h.sendWithMenu(chatID, formatIndicators(cfg, symbol, rows))
+}
+
+// indicatorRow — значения индикаторов символа на одном таймфрейме.
+type indicatorRow struct {
+	timeframe string
+	price     float64
+	values    rsi.StochRSIValues
+	zone      string // upper, lower или пусто — по профилю чата
+	err       error
+}
+
+func (h *Handler) lookupIndicators(cfg config.Config, chatID int64, symbol, timeframe string) indicatorRow {
+	row := indicatorRow{timeframe: timeframe}
+	candles, err := h.ex.Candles(symbol, timeframe, cfg.CandleLimit)
+	if err == nil && cfg.BarMode == "closed" {
+		candles = exchange.ClosedCandles(candles)
+	}
+	if err == nil && len(candles) < cfg.WarmupBars() {
+		err = fmt.Errorf("мало истории: %d свечей", len(candles))
+	}
+	if err != nil {
+		log.Printf("Ошибка свечей %s %s для /rsi: %v", symbol, timeframe, err)
+		row.err = err
+		return row
+	}
+
+	closes := exchange.Closes(candles)
+	row.price = closes[len(closes)-1]
+	row.values = rsi.CalcStochRSI(closes, cfg.RSIPeriod, cfg.StochPeriod, cfg.SmoothK, cfg.SmoothD)
+
+	var ruleResults map[string]bool
+	if len(cfg.SignalRules) > 0 {
+		src := rules.NewIndicatorSource(closes, cfg.RSIPeriod, cfg.StochPeriod, cfg.SmoothK, cfg.SmoothD, row.values)
+		ruleResults = make(map[string]bool, len(cfg.SignalRules))
+		for mode, rule := range cfg.SignalRules {
+			ruleResults[mode] = rule.Eval(src)
+		}
+	}
+	profile, ok := h.subs.Get(chatID)
+	if !ok {
+		profile = cfg.DefaultProfile()
+	}
+	profile.Mode = "both" // зону показываем при любом режиме профиля
+	row.zone, _ = profile.Evaluate(row.values, cfg.StochLowerSlack, ruleResults)
+	return row
+}
+
+func formatIndicators(cfg config.Config, symbol string, rows []indicatorRow) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 *%s*\nRSI(%d), Stoch RSI(%d, %d, %d)", symbol, cfg.RSIPeriod, cfg.StochPeriod, cfg.SmoothK, cfg.SmoothD)
+	if cfg.BarMode == "closed" {
+		b.WriteString(", по закрытым свечам")
+	}
+	b.WriteString("\n\n```\nTF     RSI  rawK     K     D\n")
+	var price float64
+	for _, r := range rows {
+		if r.err != nil {
+			fmt.Fprintf(&b, "%-4s     —\n", humanTimeframe(r.timeframe))
+			continue
+		}
+		price = r.price
+		mark := ""
+		switch r.zone {
+		case "upper":
+			mark = " ▲"
+		case "lower":
+			mark = " ▼"
+		}
+		fmt.Fprintf(&b, "%-4s %5.1f %5.1f %5.1f %5.1f%s\n", humanTimeframe(r.timeframe), r.values.RSI, r.values.RawK, r.values.K, r.values.D, mark)
+	}
+	b.WriteString("```")
+	if price != 0 {
+		fmt.Fprintf(&b, "\nЦена: `%g`", price)
+	}
+	b.WriteString("\n▲ — верхняя зона, ▼ — нижняя по вашим порогам.")
+	return b.String()
+}
+
+// parseTimeframe принимает таймфрейм в виде Bybit (60, D) или привычном (1h, 4h, 1d).
+func parseTimeframe(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case "1", "1m":
+		return "1", true
+	case "5", "5m":
+		return "5", true
+	case "15", "15m":
+		return "15", true
+	case "60", "1h", "h1":
+		return "60", true
+	case "240", "4h", "h4":
+		return "240", true
+	case "d", "1d", "d1":
+		return "D", true
+	}
+	return "", false
+}
+
+// matchSymbol ищет пару биржи по запросу пользователя: точное совпадение, тикер без котировки
+// (btc → BTCUSDT), подстрока (pepe → 1000PEPEUSDT) или опечатка не больше чем в две буквы.
+// Запрос и пары сравниваются без разделителей и суффикса -SWAP, поэтому btc находит
+// и BTC-USDT-SWAP на OKX. Без fuzzy ищутся только первые два варианта. Если однозначного
+// совпадения нет, возвращает до maxSuggestions похожих пар.
+func matchSymbol(query string, pairs []string, fuzzy bool) (string, []string) {
+	q := symbolKey(query)
+	if q == "" {
+		return "", nil
+	}
+	keys := make([]string, len(pairs))
+	byKey := make(map[string]string, len(pairs))
+	for i, p := range pairs {
+		keys[i] = symbolKey(p)
+		if _, ok := byKey[keys[i]]; !ok {
+			byKey[keys[i]] = p
+		}
+	}
+	for _, candidate := range []string{q, q + "USDT"} {
+		if p, ok := byKey[candidate]; ok {
+			return p, nil
+		}
+	}
+	if !fuzzy {
+		return "", nil
+	}
+
+	var contains []int
+	for i, k := range keys {
+		if strings.Contains(k, q) {
+			contains = append(contains, i)
+		}
+	}
+	if len(contains) == 1 {
+		return pairs[contains[0]], nil
+	}
+	if len(contains) > 1 {
+		sort.Slice(contains, func(i, j int) bool {
+			a, b := keys[contains[i]], keys[contains[j]]
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return a < b
+		})
+		suggestions := make([]string, 0, maxSuggestions)
+		for _, i := range contains[:min(len(contains), maxSuggestions)] {
+			suggestions = append(suggestions, pairs[i])
+		}
+		return "", suggestions
+	}
+
+	base := strings.TrimSuffix(q, "USDT")
+	if len(base) < 3 {
+		return "", nil
+	}
+	best := 3
+	var closest []string
+	for i, k := range keys {
+		d := levenshtein(base, strings.TrimSuffix(k, "USDT"))
+		switch {
+		case d < best:
+			best, closest = d, []string{pairs[i]}
+		case d == best:
+			closest = append(closest, pairs[i])
+		}
+	}
+	if len(closest) == 1 {
+		return closest[0], nil
+	}
+	sort.Strings(closest)
+	return "", closest[:min(len(closest), maxSuggestions)]
+}
+
+// symbolKey приводит символ к виду для сравнения: заглавные буквы и цифры без разделителей
+// и без суффикса бессрочного контракта OKX (BTC-USDT-SWAP → BTCUSDT).
+func symbolKey(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	symbol = strings.TrimSuffix(symbol, "-SWAP")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, symbol)
+}
+
+// levenshtein — число вставок, удалений и замен букв, превращающих a в b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// looksLikeSymbol отбирает обычные сообщения, похожие на тикер: одно слово из букв и цифр
+// с необязательным разделителем котировки (btc, BTCUSDT, eth/usdt).
+func looksLikeSymbol(text string) bool {
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || len(text) > 24 {
+		return false
+	}
+	for _, r := range text {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '/' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchSymbol(t *testing.T) {
+	pairs := []string{"BTCUSDT", "BTCPERP", "ETHUSDT", "ETHFIUSDT", "1000PEPEUSDT", "SOLUSDT", "DOGEUSDT"}
+	for _, tc := range []struct {
+		query       string
+		fuzzy       bool
+		want        string
+		suggestions []string
+	}{
+		{query: "BTCUSDT", want: "BTCUSDT"},
+		{query: "btc", want: "BTCUSDT"},
+		{query: "eth/usdt", want: "ETHUSDT"},
+		{query: "pepe", fuzzy: true, want: "1000PEPEUSDT"},
+		{query: "pepe"}, // обычный текст без fuzzy — только точный тикер
+		{query: "ETHF", fuzzy: true, want: "ETHFIUSDT"},
+		{query: "BT", fuzzy: true, suggestions: []string{"BTCPERP", "BTCUSDT"}},
+		{query: "DOEG", fuzzy: true, want: "DOGEUSDT"},
+		{query: "XYZQW", fuzzy: true},
+	} {
+		got, suggestions := matchSymbol(tc.query, pairs, tc.fuzzy)
+		if got != tc.want || !slices.Equal(suggestions, tc.suggestions) {
+			t.Errorf("matchSymbol(%q, fuzzy=%v) = %q, %v; want %q, %v", tc.query, tc.fuzzy, got, suggestions, tc.want, tc.suggestions)
+		}
+	}
+}
+
+func TestMatchSymbolOKX(t *testing.T) {
+	pairs := []string{"BTC-USDT-SWAP", "ETH-USDT-SWAP", "ETHFI-USDT-SWAP", "DOGE-USDT-SWAP", "BTC-USD-SWAP"}
+	for _, tc := range []struct {
+		query       string
+		fuzzy       bool
+		want        string
+		suggestions []string
+	}{
+		{query: "BTC-USDT-SWAP", want: "BTC-USDT-SWAP"},
+		{query: "btc", want: "BTC-USDT-SWAP"},
+		{query: "BTCUSDT", want: "BTC-USDT-SWAP"},
+		{query: "eth/usdt", want: "ETH-USDT-SWAP"},
+		{query: "btc-usd", want: "BTC-USD-SWAP"},
+		{query: "ETHF", fuzzy: true, want: "ETHFI-USDT-SWAP"},
+		{query: "DOEG", fuzzy: true, want: "DOGE-USDT-SWAP"},
+	} {
+		got, suggestions := matchSymbol(tc.query, pairs, tc.fuzzy)
+		if got != tc.want || !slices.Equal(suggestions, tc.suggestions) {
+			t.Errorf("matchSymbol(%q, fuzzy=%v) = %q, %v; want %q, %v", tc.query, tc.fuzzy, got, suggestions, tc.want, tc.suggestions)
+		}
+	}
+}
+
+func TestParseTimeframe(t *testing.T) {
+	for in, want := range map[string]string{"15": "15", "1h": "60", "4H": "240", "d": "D", "1d": "D"} {
+		if got, ok := parseTimeframe(in); !ok || got != want {
+			t.Errorf("parseTimeframe(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := parseTimeframe("3h"); ok {
+		t.Error("parseTimeframe(3h) accepted unsupported timeframe")
+	}
+}
+
+func TestUnknownInputIsEscaped(t *testing.T) {
+	h, tg := newWatchHandler(t, []string{"BTCUSDT"})
+
+	h.showIndicators(1, "BTC 4_h", false)
+	if reply := tg.reply(t, 1); !strings.Contains(reply, `«4\_h»`) {
+		t.Fatalf("reply %q does not escape the timeframe", reply)
+	}
+	h.showIndicators(1, "*zz_top*", false)
+	if reply := tg.reply(t, 2); !strings.Contains(reply, `«\*zz\_top\*»`) {
+		t.Fatalf("reply %q does not escape the symbol", reply)
+	}
+	h.handleSymbolCommand(1, "watch", "[zz_top")
+	if reply := tg.reply(t, 3); !strings.Contains(reply, `\[zz\_top`) {
+		t.Fatalf("reply %q does not escape the /watch argument", reply)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+// paramSpec — редактируемый через клавиатуру параметр: либо параметр расчёта бота (config),
+// либо порог профиля подписчика.
+type paramSpec struct {
+	key          string
+	label        string
+	options      []string
+	applyConfig  func(c *config.Config, v float64)
+	applyProfile func(p *subscribers.Profile, v float64)
+}
+
+var indicatorParams = []paramSpec{
+	{key: "rsi_period", label: "RSI период", options: []string{"7", "9", "14", "21"},
+		applyConfig: func(c *config.Config, v float64) { c.RSIPeriod = int(v) }},
+	{key: "stoch_period", label: "Stoch период", options: []string{"3", "7", "14", "21"},
+		applyConfig: func(c *config.Config, v float64) { c.StochPeriod = int(v) }},
+	{key: "smooth_k", label: "Smooth %K", options: []string{"1", "3", "5"},
+		applyConfig: func(c *config.Config, v float64) { c.SmoothK = int(v) }},
+	{key: "smooth_d", label: "Smooth %D", options: []string{"1", "3", "5"},
+		applyConfig: func(c *config.Config, v float64) { c.SmoothD = int(v) }},
+}
+
+var thresholdParams = []paramSpec{
+	{key: "rsi_upper", label: "RSI upper", options: []string{"65", "70", "75", "80"},
+		applyProfile: func(p *subscribers.Profile, v float64) { p.RSIUpper = v }},
+	{key: "rsi_lower", label: "RSI lower", options: []string{"20", "25", "30", "35"},
+		applyProfile: func(p *subscribers.Profile, v float64) { p.RSILower = v }},
+	{key: "stoch_upper", label: "%K upper", options: []string{"80", "90", "95", "99.99"},
+		applyProfile: func(p *subscribers.Profile, v float64) { p.StochUpper = v }},
+	{key: "stoch_lower", label: "%K lower", options: []string{"0", "5", "10", "20"},
+		applyProfile: func(p *subscribers.Profile, v float64) { p.StochLower = v }},
+}
+
+func findParam(key string) (paramSpec, bool) {
+	for _, specs := range [][]paramSpec{indicatorParams, thresholdParams} {
+		for _, spec := range specs {
+			if spec.key == key {
+				return spec, true
+			}
+		}
+	}
+	return paramSpec{}, false
+}
+
+// sendParamsMenu показывает кнопки выбора параметра из группы.
+func (h *Handler) sendParamsMenu(chatID int64, title string, specs []paramSpec) {
+	options := make([][]string, 0, len(specs))
+	for _, spec := range specs {
+		options = append(options, []string{spec.label, "param_" + spec.key})
+	}
+	h.sendSubmenu(chatID, title, options, "settings")
+}
+
+// handleParamCallback обрабатывает кнопки param_<key> (выбор значения) и set_<key>=<value> (сохранение).
+// Возвращает текст ответа; пустая строка значит, что показано подменю.
+func (h *Handler) handleParamCallback(chatID int64, data string) string {
+	if h.cfg.Get().LockTimeframe {
+		return "⚠️ Параметры зафиксированы в конфиге бота."
+	}
+	if key, ok := strings.CutPrefix(data, "param_"); ok {
+		spec, ok := findParam(key)
+		if !ok {
+			return ""
+		}
+		options := make([][]string, 0, len(spec.options))
+		for _, opt := range spec.options {
+			options = append(options, []string{opt, "set_" + spec.key + "=" + opt})
+		}
+		back := "menu_indicators"
+		if spec.applyProfile != nil {
+			back = "menu_thresholds"
+		}
+		h.sendSubmenu(chatID, spec.label+":", options, back)
+		return ""
+	}
+
+	key, raw, _ := strings.Cut(strings.TrimPrefix(data, "set_"), "=")
+	spec, ok := findParam(key)
+	if !ok {
+		return ""
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return "⚠️ Некорректное значение."
+	}
+	if spec.applyProfile != nil {
+		return h.updateProfile(chatID, func(p *subscribers.Profile) { spec.applyProfile(p, value) },
+			fmt.Sprintf("✅ %s: %s", spec.label, raw))
+	}
+	if err := h.cfg.Update(func(c *config.Config) { spec.applyConfig(c, value) }); err != nil {
+		return fmt.Sprintf("⚠️ Не удалось изменить %s: %v", spec.label, err)
+	}
+	return fmt.Sprintf("✅ %s: %s", spec.label, raw)
+}
+
+// describeIndicators возвращает параметры расчёта индикаторов бота.
+func describeIndicators(c config.Config) string {
+	return fmt.Sprintf(
+		"RSI период: *%d*\nStoch период: *%d*\nSmoothing %%K/%%D: *%d/%d*\nДопуск %%K у нижнего порога: *%.2f*",
+		c.RSIPeriod, c.StochPeriod, c.SmoothK, c.SmoothD, c.StochLowerSlack,
+	)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/outcomes"
+)
+
+// showStats отправляет результативность сигналов бота: долю попаданий, среднюю доходность
+// и средние MFE/MAE по таймфреймам, режимам и горизонтам. args — необязательное число дней.
+func (h *Handler) showStats(chatID int64, args string) {
+	var since time.Time
+	period := "за всё время"
+	if args = strings.TrimSpace(args); args != "" {
+		days, err := strconv.Atoi(args)
+		if err != nil || days <= 0 {
+			h.sendWithMenu(chatID, "⚠️ Формат: /stats или /stats 7 — статистика за последние 7 дней.")
+			return
+		}
+		since = time.Now().AddDate(0, 0, -days)
+		period = fmt.Sprintf("за %d дн.", days)
+	}
+
+	stats := h.outcomes.Stats(since)
+	if len(stats) == 0 {
+		h.sendWithMenu(chatID, fmt.Sprintf("📈 *Статистика сигналов* (%s)\n\nПока нет сигналов с закрывшимися горизонтами.", period))
+		return
+	}
+	h.sendWithMenu(chatID, formatStats(stats, period))
+}
+
+func formatStats(stats []outcomes.Stat, period string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 *Статистика сигналов* (%s)\n", period)
+	var group string
+	for _, st := range stats {
+		if g := st.Timeframe + "|" + st.Mode; g != group {
+			group = g
+			fmt.Fprintf(&b, "\n*%s · %s*\n", humanTimeframe(st.Timeframe), humanMode(st.Mode))
+		}
+		fmt.Fprintf(&b, "%d св.: %d сигн., попаданий %.0f%%, доходность %+.2f%%, MFE %.2f%%, MAE %.2f%%\n",
+			st.Bars, st.Count, st.HitRate(), st.AvgReturn, st.AvgMFE, st.AvgMAE)
+	}
+	b.WriteString("\nПопадание — цена пошла в сторону сигнала: вниз после Upper, вверх после Lower.")
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/leaderboard"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// topPageSize — сколько пар каждой стороны рейтинга показывать на странице /top.
+const topPageSize = 10
+
+// showTop отправляет первую страницу рейтинга по RSI для таймфрейма бота
+// или таймфрейма из аргумента команды.
+func (h *Handler) showTop(chatID int64, args string) {
+	timeframe := h.cfg.Get().Timeframe
+	if args = strings.TrimSpace(args); args != "" {
+		tf, ok := parseTimeframe(args)
+		if !ok {
+			h.sendWithMenu(chatID, "⚠️ Формат: /top или /top 4h")
+			return
+		}
+		timeframe = tf
+	}
+	text, kb := h.topPage(timeframe, 0)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	if kb != nil {
+		msg.ReplyMarkup = *kb
+	}
+	h.send(chatID, msg)
+}
+
+// handleTopCallback листает рейтинг: data — top_<таймфрейм>_<страница>.
+func (h *Handler) handleTopCallback(query *tgbotapi.CallbackQuery) {
+	defer h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+	parts := strings.Split(query.Data, "_")
+	if len(parts) != 3 {
+		return
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return
+	}
+	text, kb := h.topPage(parts[1], page)
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = kb
+	// Повторное нажатие на ту же страницу Telegram отклоняет как «message is not modified» — это не ошибка.
+	chatID := query.Message.Chat.ID
+	h.queue.Enqueue(chatID, edit, func(_ tgbotapi.Message, err error) {
+		if err != nil && !strings.Contains(err.Error(), "not modified") {
+			log.Printf("Ошибка обновления рейтинга %d: %v", chatID, err)
+		}
+	})
+}
+
+// topPage строит страницу рейтинга: самые перекупленные пары по убыванию RSI
+// и самые перепроданные по возрастанию. Кнопки листания возвращаются, если страниц больше одной.
+func (h *Handler) topPage(timeframe string, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	ranking := h.board.Ranking(timeframe, time.Now())
+	if len(ranking) == 0 {
+		return fmt.Sprintf("🏆 *Рейтинг RSI* %s\n\nПока нет данных: сканер ещё не прошёл по парам этого таймфрейма.", humanTimeframe(timeframe)), nil
+	}
+
+	// Верхняя половина рейтинга — перекупленность, нижняя в обратном порядке — перепроданность.
+	half := (len(ranking) + 1) / 2
+	overbought := ranking[:half]
+	oversold := slices.Clone(ranking[half:])
+	slices.Reverse(oversold)
+	pages := (half + topPageSize - 1) / topPageSize
+	page = min(max(page, 0), pages-1)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏆 *Рейтинг RSI* %s · пар: %d · стр. %d/%d\n", humanTimeframe(timeframe), len(ranking), page+1, pages)
+	writeTopSection(&b, "🔥 Перекупленность", overbought, page)
+	writeTopSection(&b, "🧊 Перепроданность", oversold, page)
+
+	if pages == 1 {
+		return b.String(), nil
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️", fmt.Sprintf("top_%s_%d", timeframe, page-1)))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData("🔄", fmt.Sprintf("top_%s_%d", timeframe, page)))
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶️", fmt.Sprintf("top_%s_%d", timeframe, page+1)))
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(row)
+	return b.String(), &kb
+}
+
+func writeTopSection(b *strings.Builder, title string, entries []leaderboard.Entry, page int) {
+	start := page * topPageSize
+	if start >= len(entries) {
+		return
+	}
+	end := min(start+topPageSize, len(entries))
+	fmt.Fprintf(b, "\n%s\n```\n", title)
+	for i, e := range entries[start:end] {
+		fmt.Fprintf(b, "%3d %-14s RSI %5.1f K %5.1f D %5.1f\n", start+i+1, e.Symbol, e.Values.RSI, e.Values.K, e.Values.D)
+	}
+	b.WriteString("```\n")
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+// maxListSymbols — предел суммарной длины обоих списков символов профиля: кнопки удаления
+// вместе с кнопкой «назад» должны уместиться в 100 кнопок инлайн-клавиатуры Telegram.
+const maxListSymbols = 99
+
+// handleSymbolCommand обрабатывает /watch, /unwatch и /ignore. Без аргументов команда
+// показывает списки профиля с кнопками удаления.
+//   - /watch BTC ETH — присылать сигналы только по этим символам (символ уходит из чёрного списка);
+//   - /unwatch BTC — убрать символ из списка, /unwatch all — очистить список;
+//   - /ignore DOGE — никогда не присылать сигналы по символу (символ уходит из списка).
+func (h *Handler) handleSymbolCommand(chatID int64, command, args string) {
+	profile, ok := h.subs.Get(chatID)
+	if !ok {
+		h.sendWithMenu(chatID, "⚠️ Сначала подпишитесь на сигналы.")
+		return
+	}
+	fields := strings.FieldsFunc(args, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		h.showSymbolLists(chatID, profile, "")
+		return
+	}
+
+	if command == "unwatch" {
+		var remove []string
+		if len(fields) == 1 && strings.EqualFold(fields[0], "all") {
+			remove = profile.Symbols
+		} else {
+			// Удалить можно и символ, который уже исчез с биржи, поэтому ищем только в самом списке.
+			for _, f := range fields {
+				if symbol, _ := matchSymbol(f, profile.Symbols, false); symbol != "" {
+					remove = append(remove, symbol)
+				}
+			}
+		}
+		h.applySymbolLists(chatID, func(p *subscribers.Profile) {
+			p.Symbols = removeSymbols(p.Symbols, remove)
+		}, "✅ Убрано из списка: "+listOrNone(remove))
+		return
+	}
+
+	pairs, err := h.pairs.get(h.ex)
+	if err != nil {
+		log.Printf("Ошибка загрузки пар для /%s: %v", command, err)
+		h.sendWithMenu(chatID, "⚠️ Не удалось получить список пар с биржи, попробуйте позже.")
+		return
+	}
+	// В списки попадают только точные тикеры и тикеры без котировки: догадка по опечатке
+	// может оказаться другой монетой, поэтому её только предлагаем.
+	var symbols, unknown []string
+	for _, f := range fields {
+		if symbol, _ := matchSymbol(f, pairs, false); symbol != "" {
+			symbols = append(symbols, symbol)
+			continue
+		}
+		guess, suggestions := matchSymbol(f, pairs, true)
+		if guess != "" {
+			suggestions = []string{guess}
+		}
+		if len(suggestions) > 0 {
+			f += " (возможно: " + strings.Join(suggestions, ", ") + ")"
+		}
+		unknown = append(unknown, f)
+	}
+	note := ""
+	if len(unknown) > 0 {
+		note = fmt.Sprintf("\n⚠️ Не найдены на бирже: %s", strings.Join(unknown, ", "))
+	}
+	if len(symbols) == 0 {
+		h.sendWithMenu(chatID, escapeMarkdown(strings.TrimPrefix(note, "\n")))
+		return
+	}
+
+	if command == "ignore" {
+		h.applySymbolLists(chatID, func(p *subscribers.Profile) {
+			p.ExcludeSymbols = addSymbols(p.ExcludeSymbols, symbols)
+			p.Symbols = removeSymbols(p.Symbols, symbols)
+		}, "🔕 Больше не присылать: "+strings.Join(symbols, ", ")+note)
+		return
+	}
+	h.applySymbolLists(chatID, func(p *subscribers.Profile) {
+		p.Symbols = addSymbols(p.Symbols, symbols)
+		p.ExcludeSymbols = removeSymbols(p.ExcludeSymbols, symbols)
+	}, "⭐ Добавлено в список: "+strings.Join(symbols, ", ")+note)
+}
+
+// handleSymbolCallback обрабатывает кнопки удаления из списков: unwatch_<SYMBOL> и unignore_<SYMBOL>.
+func (h *Handler) handleSymbolCallback(chatID int64, data string) {
+	if symbol, ok := strings.CutPrefix(data, "unwatch_"); ok {
+		h.applySymbolLists(chatID, func(p *subscribers.Profile) {
+			p.Symbols = removeSymbols(p.Symbols, []string{symbol})
+		}, "✅ Убрано из списка: "+symbol)
+		return
+	}
+	symbol := strings.TrimPrefix(data, "unignore_")
+	h.applySymbolLists(chatID, func(p *subscribers.Profile) {
+		p.ExcludeSymbols = removeSymbols(p.ExcludeSymbols, []string{symbol})
+	}, "🔔 Снова присылать: "+symbol)
+}
+
+// applySymbolLists меняет списки символов профиля и показывает их заново.
+func (h *Handler) applySymbolLists(chatID int64, updater func(*subscribers.Profile), okText string) {
+	tooLong := false
+	text := h.updateProfile(chatID, func(p *subscribers.Profile) {
+		before := *p
+		updater(p)
+		if len(p.Symbols)+len(p.ExcludeSymbols) > maxListSymbols {
+			*p = before
+			tooLong = true
+		}
+	}, okText)
+	if tooLong {
+		text = fmt.Sprintf("⚠️ В двух списках вместе может быть не больше %d символов.", maxListSymbols)
+	}
+	profile, ok := h.subs.Get(chatID)
+	if !ok {
+		h.sendWithMenu(chatID, escapeMarkdown(text))
+		return
+	}
+	// Списки уходят без разметки, поэтому введённый текст в text не экранируется.
+	h.showSymbolLists(chatID, profile, text)
+}
+
+// showSymbolLists показывает список и чёрный список профиля с кнопками удаления.
+func (h *Handler) showSymbolLists(chatID int64, p subscribers.Profile, header string) {
+	var b strings.Builder
+	if header != "" {
+		b.WriteString(header + "\n\n")
+	}
+	if len(p.Symbols) > 0 {
+		fmt.Fprintf(&b, "⭐ Только символы: %s\n", strings.Join(p.Symbols, ", "))
+	} else {
+		b.WriteString("⭐ Список пуст — сигналы по всем символам.\n")
+	}
+	fmt.Fprintf(&b, "🔕 Не присылать: %s\n\n", listOrNone(p.ExcludeSymbols))
+	b.WriteString("/watch BTC ETH — только эти символы\n/ignore DOGE — никогда этот символ\n/unwatch all — очистить список\nКнопки ниже убирают символ из списка.")
+
+	options := make([][]string, 0, len(p.Symbols)+len(p.ExcludeSymbols))
+	for _, s := range p.Symbols {
+		options = append(options, []string{"⭐ ✖ " + s, "unwatch_" + s})
+	}
+	for _, s := range p.ExcludeSymbols {
+		options = append(options, []string{"🔕 ✖ " + s, "unignore_" + s})
+	}
+	h.sendSubmenu(chatID, b.String(), options, "settings")
+}
+
+func addSymbols(list, add []string) []string {
+	out := slices.Clone(list)
+	for _, s := range add {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func removeSymbols(list, remove []string) []string {
+	var out []string
+	for _, s := range list {
+		if !slices.Contains(remove, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func listOrNone(symbols []string) string {
+	if len(symbols) == 0 {
+		return "—"
+	}
+	return strings.Join(symbols, ", ")
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/delivery"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSymbolListEdits(t *testing.T) {
+	list := addSymbols([]string{"BTCUSDT"}, []string{"ETHUSDT", "BTCUSDT"})
+	if !slices.Equal(list, []string{"BTCUSDT", "ETHUSDT"}) {
+		t.Fatalf("addSymbols() = %v", list)
+	}
+	if got := removeSymbols(list, []string{"BTCUSDT", "SOLUSDT"}); !slices.Equal(got, []string{"ETHUSDT"}) {
+		t.Fatalf("removeSymbols() = %v", got)
+	}
+	if got := removeSymbols(list, list); got != nil {
+		t.Fatalf("removeSymbols(all) = %v, want empty list", got)
+	}
+}
+
+// fakePairs — биржа, у которой есть только список пар.
+type fakePairs struct{ pairs []string }
+
+func (f fakePairs) Name() string                       { return "fake" }
+func (f fakePairs) DerivativePairs() ([]string, error) { return f.pairs, nil }
+func (f fakePairs) ServerTime() (time.Time, error)     { return time.Now(), nil }
+func (f fakePairs) Candles(string, string, int) ([]exchange.Candle, error) {
+	return nil, nil
+}
+
+// fakeTelegram — сервер Bot API, запоминающий тексты отправленных сообщений.
+type fakeTelegram struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/getMe") {
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+		return
+	}
+	f.mu.Lock()
+	f.sent = append(f.sent, r.FormValue("text"))
+	f.mu.Unlock()
+	fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1}}}`)
+}
+
+// reply ждёт n-е по счёту (с 1) отправленное сообщение: ответы уходят через очередь без ожидания.
+func (f *fakeTelegram) reply(t *testing.T, n int) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f.mu.Lock()
+		if len(f.sent) >= n {
+			defer f.mu.Unlock()
+			return f.sent[n-1]
+		}
+		f.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("reply %d was not sent", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newWatchHandler(t *testing.T, pairs []string) (*Handler, *fakeTelegram) {
+	t.Helper()
+	tg := &fakeTelegram{}
+	srv := httptest.NewServer(tg)
+	t.Cleanup(srv.Close)
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPI: %v", err)
+	}
+	subs := subscribers.NewStore(filepath.Join(t.TempDir(), "subscribers.json"), func() subscribers.Profile {
+		return subscribers.Profile{Mode: subscribers.ModeUpper, Timeframes: []string{"60"}, RSIUpper: 70, RSILower: 30, StochUpper: 80, StochLower: 20}
+	})
+	if _, err := subs.Subscribe(1); err != nil {
+		t.Fatal(err)
+	}
+	queue := delivery.New(api, delivery.Options{GlobalRate: 1000, ChatRate: 1000})
+	t.Cleanup(queue.Stop)
+	return New(api, queue, nil, subs, nil, fakePairs{pairs: pairs}, nil), tg
+}
+
+func TestWatchAddsOnlyExactSymbols(t *testing.T) {
+	h, tg := newWatchHandler(t, []string{"BTCUSDT", "ETHUSDT", "DOGEUSDT", "1000PEPEUSDT"})
+
+	h.handleSymbolCommand(1, "watch", "btc DOEG pepe ETHUSDT")
+	p, _ := h.subs.Get(1)
+	if !slices.Equal(p.Symbols, []string{"BTCUSDT", "ETHUSDT"}) {
+		t.Fatalf("Symbols = %v, want only exact matches", p.Symbols)
+	}
+	reply := tg.reply(t, 1)
+	for _, want := range []string{"DOEG (возможно: DOGEUSDT)", "pepe (возможно: 1000PEPEUSDT)"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("reply %q does not suggest %q", reply, want)
+		}
+	}
+
+	h.handleSymbolCommand(1, "watch", "DOEG")
+	if p, _ := h.subs.Get(1); len(p.Symbols) != 2 {
+		t.Fatalf("fuzzy-only /watch changed the list: %v", p.Symbols)
+	}
+
+	h.handleSymbolCommand(1, "unwatch", "btc")
+	if p, _ := h.subs.Get(1); !slices.Equal(p.Symbols, []string{"ETHUSDT"}) {
+		t.Fatalf("Symbols after /unwatch btc = %v, want [ETHUSDT]", p.Symbols)
+	}
+	h.handleSymbolCommand(1, "unwatch", "eth")
+	if p, _ := h.subs.Get(1); len(p.Symbols) != 0 {
+		t.Fatalf("Symbols after /unwatch eth = %v, want empty", p.Symbols)
+	}
+}
+
+func TestSymbolListsFitKeyboard(t *testing.T) {
+	pairs := make([]string, 100)
+	for i := range pairs {
+		pairs[i] = fmt.Sprintf("C%dUSDT", i)
+	}
+	h, tg := newWatchHandler(t, pairs)
+
+	h.handleSymbolCommand(1, "watch", strings.Join(pairs[:60], " "))
+	h.handleSymbolCommand(1, "ignore", strings.Join(pairs[60:], " "))
+	p, _ := h.subs.Get(1)
+	if len(p.Symbols) != 60 || len(p.ExcludeSymbols) != 0 {
+		t.Fatalf("lists = %d + %d symbols, want the 100-symbol edit rejected", len(p.Symbols), len(p.ExcludeSymbols))
+	}
+	if reply := tg.reply(t, 2); !strings.Contains(reply, "не больше 99") {
+		t.Fatalf("reply %q does not explain the limit", reply)
+	}
+
+	h.handleSymbolCommand(1, "ignore", strings.Join(pairs[61:], " "))
+	if p, _ := h.subs.Get(1); len(p.Symbols)+len(p.ExcludeSymbols) != 99 {
+		t.Fatalf("lists = %d + %d symbols, want 99", len(p.Symbols), len(p.ExcludeSymbols))
+	}
+}
@@ -0,0 +1,171 @@
+// Package history хранит журнал отправленных сигналов в JSONL-файле с ротацией по размеру
+// и позволяет выбирать записи по времени, символу, таймфрейму и режиму.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record — один разосланный сигнал.
+type Record struct {
+	SentAt     time.Time         `json:"sent_at"`
+	Symbol     string            `json:"symbol"`
+	Timeframe  string            `json:"timeframe"`
+	Mode       string            `json:"mode"`
+	BarTime    time.Time         `json:"bar_time"` // время открытия свечи сигнала
+	Price      float64           `json:"price"`    // цена закрытия (текущая цена для незакрытой свечи)
+	RSI        float64           `json:"rsi"`
+	RawK       float64           `json:"raw_k"`
+	K          float64           `json:"k"`
+	D          float64           `json:"d"`
+	Recipients []int64           `json:"recipients"`
+	Errors     map[int64]string  `json:"errors,omitempty"`      // чат → ошибка доставки
+	Pruned     map[int64]string  `json:"pruned,omitempty"`      // чат → причина отписки: blocked, chat_not_found, migrated:<новый id>
+	Sinks      []string          `json:"sinks,omitempty"`       // каналы доставки из конфига
+	SinkErrors map[string]string `json:"sink_errors,omitempty"` // канал → ошибка доставки
+}
+
+// Query — фильтр выборки. Пустые поля не ограничивают выборку.
+type Query struct {
+	Since     time.Time // SentAt ≥ Since
+	Until     time.Time // SentAt < Until
+	Symbol    string
+	Timeframe string
+	Mode      string
+	Limit     int // если > 0 — только последние Limit записей
+}
+
+func (q Query) match(r Record) bool {
+	switch {
+	case !q.Since.IsZero() && r.SentAt.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !r.SentAt.Before(q.Until):
+		return false
+	case q.Symbol != "" && r.Symbol != q.Symbol:
+		return false
+	case q.Timeframe != "" && r.Timeframe != q.Timeframe:
+		return false
+	case q.Mode != "" && r.Mode != q.Mode:
+		return false
+	}
+	return true
+}
+
+// Store — журнал сигналов. Когда файл дорастает до maxSize байт, он переименовывается в path.1,
+// прежние архивы сдвигаются (path.1 → path.2 …), а архив сверх keep удаляется.
+type Store struct {
+	path    string
+	maxSize int64
+	keep    int
+	mu      sync.Mutex
+}
+
+// New создаёт журнал в файле path с ротацией после maxSize байт и keep архивами.
+func New(path string, maxSize int64, keep int) *Store {
+	return &Store{path: path, maxSize: maxSize, keep: keep}
+}
+
+// Path возвращает путь к текущему файлу журнала.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append дописывает запись в журнал.
+func (s *Store) Append(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info, err := os.Stat(s.path); err == nil && s.maxSize > 0 && info.Size() > 0 && info.Size()+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("ротация %s: %w", s.path, err)
+		}
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Store) rotate() error {
+	if s.keep <= 0 {
+		return os.Remove(s.path)
+	}
+	if err := os.Remove(s.archive(s.keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := s.keep - 1; i >= 1; i-- {
+		if err := os.Rename(s.archive(i), s.archive(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.path, s.archive(1))
+}
+
+func (s *Store) archive(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// Query возвращает записи журнала и архивов, подходящие под фильтр, от старых к новым.
+func (s *Store) Query(q Query) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Record
+	for i := s.keep; i >= 0; i-- {
+		path := s.path
+		if i > 0 {
+			path = s.archive(i)
+		}
+		records, err := readFile(path, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, records...)
+		if q.Limit > 0 && len(out) > q.Limit {
+			out = out[len(out)-q.Limit:]
+		}
+	}
+	return out, nil
+}
+
+// readFile читает записи одного файла. Повреждённые строки (например, оборванная запись
+// при аварийной остановке) пропускаются, чтобы не терять остальной журнал.
+func readFile(path string, q Query) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if q.match(r) {
+			out = append(out, r)
+		}
+	}
+	return out, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendRotatesAndQueriesAcrossArchives(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signals.jsonl")
+	// Одна запись занимает ~200 байт: каждый Append после первого уходит в новый файл.
+	s := New(path, 250, 2)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	symbols := []string{"BTCUSDT", "ETHUSDT", "BTCUSDT", "SOLUSDT"}
+	for i, symbol := range symbols {
+		r := Record{SentAt: start.Add(time.Duration(i) * time.Hour), Symbol: symbol, Timeframe: "60", Mode: "upper", Recipients: []int64{1}}
+		if err := s.Append(r); err != nil {
+			t.Fatalf("Append(%d) error: %v", i, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("archive beyond keep should be removed, stat error = %v", err)
+	}
+
+	all, err := s.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query() error: %v", err)
+	}
+	// Первая запись ушла вместе с самым старым архивом.
+	if len(all) != 3 || all[0].Symbol != "ETHUSDT" || all[2].Symbol != "SOLUSDT" {
+		t.Fatalf("Query() = %+v, want the last three records in order", all)
+	}
+
+	btc, err := s.Query(Query{Symbol: "BTCUSDT", Since: start.Add(time.Hour)})
+	if err != nil || len(btc) != 1 || !btc[0].SentAt.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("Query(BTCUSDT since 1h) = %+v, %v", btc, err)
+	}
+	last, err := s.Query(Query{Limit: 1})
+	if err != nil || len(last) != 1 || last[0].Symbol != "SOLUSDT" {
+		t.Fatalf("Query(limit 1) = %+v, %v", last, err)
+	}
+}
+
+func TestQuerySkipsCorruptedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signals.jsonl")
+	data := `{"symbol":"BTCUSDT","mode":"lower"}` + "\n" + `{"symbol":"ETH`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := New(path, 0, 0).Query(Query{Mode: "lower"})
+	if err != nil || len(records) != 1 || records[0].Symbol != "BTCUSDT" {
+		t.Fatalf("Query() = %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package leaderboard хранит последние значения индикаторов по всем парам, которые
+// посчитал сканер, и строит по ним рейтинг перекупленности и перепроданности.
+package leaderboard
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+// minStale — нижняя граница возраста, после которого значения пары не попадают в рейтинг:
+// пара пропала из листинга или сканер давно её не обновлял.
+const minStale = 5 * time.Minute
+
+// Entry — последние значения индикаторов пары на таймфрейме.
+type Entry struct {
+	Symbol  string
+	Price   float64
+	Values  rsi.StochRSIValues
+	BarTime time.Time // свеча, по которой посчитаны значения
+	Updated time.Time // когда значения посчитаны
+}
+
+// Board — последние значения по парам и таймфреймам. Безопасен для параллельного использования.
+type Board struct {
+	mu      sync.RWMutex
+	entries map[string]map[string]Entry // таймфрейм → символ → значения
+}
+
+// New создаёт пустой рейтинг.
+func New() *Board {
+	return &Board{entries: make(map[string]map[string]Entry)}
+}
+
+// Update запоминает значения пары, посчитанные в текущем проходе сканера.
+func (b *Board) Update(timeframe string, e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byTF, ok := b.entries[timeframe]
+	if !ok {
+		byTF = make(map[string]Entry)
+		b.entries[timeframe] = byTF
+	}
+	byTF[e.Symbol] = e
+}
+
+// Ranking возвращает актуальные значения таймфрейма по убыванию RSI: начало списка —
+// самые перекупленные пары, конец — самые перепроданные. Значения старше двух свечей
+// таймфрейма (но не меньше minStale) на момент now отбрасываются.
+func (b *Board) Ranking(timeframe string, now time.Time) []Entry {
+	stale := max(2*exchange.TimeframeDuration(timeframe), minStale)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byTF := b.entries[timeframe]
+	out := make([]Entry, 0, len(byTF))
+	for symbol, e := range byTF {
+		if now.Sub(e.Updated) > stale {
+			delete(byTF, symbol)
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Values.RSI != out[j].Values.RSI {
+			return out[i].Values.RSI > out[j].Values.RSI
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
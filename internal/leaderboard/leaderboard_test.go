@@ -0,0 +1,37 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+func TestRankingSortsAndDropsStale(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := New()
+	for _, e := range []struct {
+		symbol string
+		rsi    float64
+		age    time.Duration
+	}{
+		{"BTCUSDT", 55, time.Minute},
+		{"ETHUSDT", 81, time.Minute},
+		{"SOLUSDT", 22, 30 * time.Minute},
+		{"OLDUSDT", 99, 3 * time.Hour}, // старше двух часовых свечей
+	} {
+		b.Update("60", Entry{Symbol: e.symbol, Values: rsi.StochRSIValues{RSI: e.rsi}, Updated: now.Add(-e.age)})
+	}
+
+	got := b.Ranking("60", now)
+	var symbols []string
+	for _, e := range got {
+		symbols = append(symbols, e.Symbol)
+	}
+	if len(symbols) != 3 || symbols[0] != "ETHUSDT" || symbols[1] != "BTCUSDT" || symbols[2] != "SOLUSDT" {
+		t.Fatalf("Ranking() = %v, want ETHUSDT, BTCUSDT, SOLUSDT", symbols)
+	}
+	if len(b.Ranking("240", now)) != 0 {
+		t.Fatal("Ranking() for unscanned timeframe is not empty")
+	}
+}
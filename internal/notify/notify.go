@@ -2,71 +2,662 @@
 package notify
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/chart"
+	"grevtsevalex/crypto-bot/internal/delivery"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/fileutil"
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/subscribers"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Signal — рассчитанные значения индикаторов по символу и таймфрейму.
+type Signal struct {
+	Symbol      string
+	Timeframe   string
+	BarTime     time.Time // время открытия свечи, по которой посчитаны значения
+	Price       float64   // цена закрытия этой свечи
+	Values      rsi.StochRSIValues
+	RSIPeriod   int
+	StochPeriod int
+	SmoothK     int
+	SmoothD     int
+	LowerKSlack float64         // допуск %K над нижним порогом профиля
+	RuleResults map[string]bool // результаты правил из конфига по режимам (upper/lower)
+	// Candles — свечи, по которым посчитаны значения; если ChartBars > 0, к сигналу
+	// прикладывается график последних ChartBars из них.
+	Candles   []exchange.Candle
+	ChartBars int
+}
+
+// signalState — отправленный чату сигнал по символу, пока символ остаётся в зоне.
+type signalState struct {
+	Mode    string    `json:"mode"`
+	BarTime time.Time `json:"bar_time"` // свеча, по которой сигнал был отправлен
+	Seen    time.Time `json:"seen"`     // последняя свеча, на которой символ ещё был в зоне
+}
+
+// stateSaveInterval — как часто состояние дедупликации сбрасывается на диск.
+const stateSaveInterval = 10 * time.Second
+
+// minStateExpiry — нижняя граница срока жизни состояния: полный проход сканера
+// по всем парам на минутном таймфрейме не должен успевать его исчерпать.
+const minStateExpiry = 10 * time.Minute
+
+const (
+	// sinkWorkers — сколько каналов доставки обслуживается одновременно.
+	sinkWorkers = 4
+	// sinkQueueSize — сколько сигналов может ждать доставки в каналы; лишние не доставляются.
+	sinkQueueSize = 100
+)
+
+// Sender ставит сообщение в чат Telegram в очередь отправки и вызывает done, когда оно
+// отправлено или окончательно не удалось; его реализует delivery.Queue.
+type Sender interface {
+	Enqueue(chatID int64, msg tgbotapi.Chattable, done func(tgbotapi.Message, error))
+}
+
+// Subscribers — подписчики бота. Чаты, которые больше не могут получать сообщения,
+// нотификатор отписывает сам, а группы, ставшие супергруппами, переносит на новый chat_id.
+type Subscribers interface {
+	All() map[int64]subscribers.Profile
+	Unsubscribe(chatID int64) (bool, error)
+	Migrate(from, to int64) (bool, error)
+}
+
 type Notifier struct {
-	bot        *tgbotapi.BotAPI
-	signalMode string
-	lastSignal map[string]string
+	sender     Sender
+	lastSignal map[string]signalState
 	mu         sync.RWMutex
-	getSubs    func() map[int64]bool
+	subs       Subscribers
+	history    *history.Store
+	routes     []Route
+
+	statePath  string
+	expiryBars int
+	dirty      bool
+	savedAt    time.Time
+
+	// Рассылка идёт в фоне, чтобы очередь Telegram, медленный вебхук или почтовый сервер
+	// не задерживали сканер: чатам — через очередь sender, каналам — через sinkJobs.
+	asyncMu  sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup // рассылки, ещё не записанные в журнал
+	sinkJobs chan *dispatch
+	sinkWG   sync.WaitGroup
+}
+
+// dispatch — рассылка сигнала одного режима чатам и каналам. В журнал она записывается,
+// когда закончены обе части.
+type dispatch struct {
+	sig     Signal
+	mode    string
+	payload Payload
+	chats   []int64
+	sinks   []Sink
+	tracked bool // учтена в inflight
+
+	mu       sync.Mutex
+	left     int // незаконченные части: чаты и каналы
+	errs     map[int64]error
+	pruned   map[int64]string
+	sinkErrs map[string]error
 }
 
-func New(bot *tgbotapi.BotAPI, signalMode string, getSubs func() map[int64]bool) *Notifier {
+// errSinkQueueFull — сигнал не доставлен в канал: очередь доставки переполнена или остановлена.
+var errSinkQueueFull = errors.New("очередь доставки в каналы переполнена или остановлена")
+
+// New создаёт нотификатор, отправляющий сообщения через sender.
+// Если history не nil, каждый разосланный сигнал записывается в журнал.
+func New(sender Sender, subs Subscribers, history *history.Store) *Notifier {
 	return &Notifier{
-		bot:        bot,
-		signalMode: signalMode,
-		lastSignal: make(map[string]string),
-		getSubs:    getSubs,
+		sender:     sender,
+		lastSignal: make(map[string]signalState),
+		subs:       subs,
+		history:    history,
+	}
+}
+
+// Route — канал доставки и профиль, по которому он получает сигналы.
+type Route struct {
+	Sink    Sink
+	Profile subscribers.Profile
+}
+
+// AddRoute подключает канал доставки и при первом вызове запускает доставку в каналы.
+// Вызывается до начала рассылки.
+func (n *Notifier) AddRoute(r Route) {
+	n.routes = append(n.routes, r)
+	if n.sinkJobs != nil {
+		return
+	}
+	n.sinkJobs = make(chan *dispatch, sinkQueueSize)
+	for range sinkWorkers {
+		n.sinkWG.Add(1)
+		go n.deliverSinks()
 	}
 }
 
-// ShouldSend возвращает true, если для символа ещё не отправляли сигнал в текущем заходе в зону >= порога.
-func (n *Notifier) ShouldSend(symbol string) bool {
+// Close дожидается рассылки сигналов, уже поставленных в очередь, и их записи в журнал;
+// очередь sender при этом должна работать. Сигналы, пришедшие после Close, в каналы
+// не доставляются.
+func (n *Notifier) Close() {
+	n.asyncMu.Lock()
+	if !n.closed {
+		n.closed = true
+		if n.sinkJobs != nil {
+			close(n.sinkJobs)
+		}
+	}
+	n.asyncMu.Unlock()
+	n.sinkWG.Wait()
+	n.inflight.Wait()
+}
+
+func signalKey(chatID int64, symbol, timeframe string) string {
+	return fmt.Sprintf("%d|%s|%s", chatID, symbol, timeframe)
+}
+
+// sinkKey — ключ дедупликации канала; дедупликация у каналов та же, что у чатов.
+func sinkKey(name, symbol, timeframe string) string {
+	return fmt.Sprintf("sink:%s|%s|%s", name, symbol, timeframe)
+}
+
+// LoadState включает сохранение состояния дедупликации в файл path и загружает его,
+// чтобы после перезапуска не рассылать повторно сигналы по символам, которые всё ещё в зоне.
+// Запись считается устаревшей, если символ не подтверждал зону дольше expiryBars свечей
+// своего таймфрейма: за это время он мог выйти из зоны и войти заново.
+func (n *Notifier) LoadState(path string, expiryBars int) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	if n.lastSignal[symbol] == n.signalMode {
+	n.statePath = path
+	n.expiryBars = expiryBars
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var saved map[string]signalState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	now := time.Now()
+	for key, st := range saved {
+		timeframe := key[strings.LastIndexByte(key, '|')+1:]
+		if n.expired(st, timeframe, now) {
+			continue
+		}
+		n.lastSignal[key] = st
+	}
+	return nil
+}
+
+// SaveState сразу записывает состояние дедупликации, если оно изменилось.
+func (n *Notifier) SaveState() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.saveLocked()
+}
+
+func (n *Notifier) saveLocked() error {
+	if n.statePath == "" || !n.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.lastSignal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(n.statePath, data, 0644); err != nil {
+		return err
+	}
+	n.dirty = false
+	n.savedAt = time.Now()
+	return nil
+}
+
+// persistLocked сохраняет состояние не чаще stateSaveInterval.
+func (n *Notifier) persistLocked() {
+	if time.Since(n.savedAt) < stateSaveInterval {
+		return
+	}
+	if err := n.saveLocked(); err != nil {
+		log.Printf("Не удалось сохранить состояние сигналов: %v", err)
+	}
+}
+
+// expired сообщает, что последняя подтверждённая свеча зоны старше срока жизни записи на момент at.
+func (n *Notifier) expired(st signalState, timeframe string, at time.Time) bool {
+	if n.expiryBars <= 0 || st.Seen.IsZero() || at.IsZero() {
 		return false
 	}
-	n.lastSignal[symbol] = n.signalMode
+	expiry := time.Duration(n.expiryBars) * exchange.TimeframeDuration(timeframe)
+	if expiry < minStateExpiry {
+		expiry = minStateExpiry
+	}
+	return at.Sub(st.Seen) > expiry
+}
+
+// ShouldSend возвращает true, если чату ещё не отправляли сигнал по символу в текущем заходе в зону.
+// barTime — время открытия свечи, на которой символ в зоне; по ней же истекают устаревшие записи.
+func (n *Notifier) ShouldSend(chatID int64, symbol, timeframe, mode string, barTime time.Time) bool {
+	return n.shouldSend(signalKey(chatID, symbol, timeframe), timeframe, mode, barTime)
+}
+
+func (n *Notifier) shouldSend(key, timeframe, mode string, barTime time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	defer n.persistLocked()
+	st, ok := n.lastSignal[key]
+	if ok && st.Mode == mode && !n.expired(st, timeframe, barTime) {
+		if barTime.After(st.Seen) {
+			st.Seen = barTime
+			n.lastSignal[key] = st
+			n.dirty = true
+		}
+		return false
+	}
+	n.lastSignal[key] = signalState{Mode: mode, BarTime: barTime, Seen: barTime}
+	n.dirty = true
 	return true
 }
 
-// ClearSignalState сбрасывает состояние символа после выхода из активной зоны.
-func (n *Notifier) ClearSignalState(symbol string) {
+// ClearSignalState сбрасывает состояние символа для чата после выхода из активной зоны.
+func (n *Notifier) ClearSignalState(chatID int64, symbol, timeframe string) {
+	n.clearState(signalKey(chatID, symbol, timeframe))
+}
+
+func (n *Notifier) clearState(key string) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	delete(n.lastSignal, symbol)
+	if _, ok := n.lastSignal[key]; !ok {
+		return
+	}
+	delete(n.lastSignal, key)
+	n.dirty = true
+	n.persistLocked()
 }
 
-// SendSignal отправляет уведомление о выбранной зоне RSI/Stoch RSI, если ещё не отправляли для этого символа.
-func (n *Notifier) SendSignal(symbol, timeframe string, rsiValue, kValue float64, rsiPeriod, stochPeriod, smoothK, smoothD int) {
-	if !n.ShouldSend(symbol) {
+// SendSignal проверяет сигнал по профилю каждого подписчика и каждого канала доставки
+// и рассылает уведомление только тем, чей профиль совпал и кому ещё не отправляли
+// этот заход в зону. Возвращает true, если уведомление ушло хотя бы одному получателю.
+func (n *Notifier) SendSignal(sig Signal) bool {
+	recipients := make(map[string][]int64)
+	for chatID, profile := range n.subs.All() {
+		if !profile.Watches(sig.Timeframe, sig.Symbol) {
+			continue
+		}
+		mode, ok := profile.Evaluate(sig.Values, sig.LowerKSlack, sig.RuleResults)
+		if !ok {
+			n.ClearSignalState(chatID, sig.Symbol, sig.Timeframe)
+			continue
+		}
+		if n.ShouldSend(chatID, sig.Symbol, sig.Timeframe, mode, sig.BarTime) {
+			recipients[mode] = append(recipients[mode], chatID)
+		}
+	}
+
+	sinks := make(map[string][]Sink)
+	for _, r := range n.routes {
+		if !r.Profile.Watches(sig.Timeframe, sig.Symbol) {
+			continue
+		}
+		key := sinkKey(r.Sink.Name(), sig.Symbol, sig.Timeframe)
+		mode, ok := r.Profile.Evaluate(sig.Values, sig.LowerKSlack, sig.RuleResults)
+		if !ok {
+			n.clearState(key)
+			continue
+		}
+		if n.shouldSend(key, sig.Timeframe, mode, sig.BarTime) {
+			sinks[mode] = append(sinks[mode], r.Sink)
+		}
+	}
+
+	if len(recipients) == 0 && len(sinks) == 0 {
+		return false
+	}
+	chartPNG := renderChart(sig)
+	for _, mode := range []string{subscribers.ModeUpper, subscribers.ModeLower} {
+		chats, targets := recipients[mode], sinks[mode]
+		if len(chats) == 0 && len(targets) == 0 {
+			continue
+		}
+		d := &dispatch{sig: sig, mode: mode, chats: chats, sinks: targets}
+		if len(chats) > 0 {
+			d.left++
+		}
+		if len(targets) > 0 {
+			d.left++
+			d.payload = newPayload(sig, mode, chartPNG)
+		}
+		n.track(d)
+		if len(chats) > 0 {
+			n.broadcast(formatSignal(sig, mode), "Markdown", chartPNG, chats, func(errs map[int64]error) {
+				pruned := n.prune(errs)
+				n.finish(d, func() { d.errs, d.pruned = errs, pruned })
+			})
+		}
+		if len(targets) > 0 {
+			n.enqueueSinks(d)
+		}
+	}
+	return true
+}
+
+// track учитывает рассылку, чтобы Close её дождался.
+func (n *Notifier) track(d *dispatch) {
+	n.asyncMu.Lock()
+	defer n.asyncMu.Unlock()
+	if !n.closed {
+		d.tracked = true
+		n.inflight.Add(1)
+	}
+}
+
+// finish сохраняет результат одной части рассылки и, если она последняя, пишет сигнал в журнал.
+func (n *Notifier) finish(d *dispatch, set func()) {
+	d.mu.Lock()
+	set()
+	d.left--
+	last := d.left == 0
+	d.mu.Unlock()
+	if !last {
+		return
+	}
+	n.record(d.sig, d.mode, d.chats, d.errs, d.pruned, d.sinks, d.sinkErrs)
+	if d.tracked {
+		n.inflight.Done()
+	}
+}
+
+// enqueueSinks ставит сигнал в очередь доставки в каналы, не дожидаясь её. Если очередь
+// заполнена, доставка по каждому каналу считается неудавшейся.
+func (n *Notifier) enqueueSinks(d *dispatch) {
+	n.asyncMu.Lock()
+	queued := false
+	if !n.closed {
+		select {
+		case n.sinkJobs <- d:
+			queued = true
+		default:
+		}
+	}
+	n.asyncMu.Unlock()
+	if queued {
+		return
+	}
+	log.Printf("Сигнал %s не доставлен в каналы: %v", d.sig.Symbol, errSinkQueueFull)
+	sinkErrs := make(map[string]error, len(d.sinks))
+	for _, sink := range d.sinks {
+		sinkErrs[sink.Name()] = errSinkQueueFull
+	}
+	n.finish(d, func() { d.sinkErrs = sinkErrs })
+}
+
+// deliverSinks доставляет сигналы из очереди в каналы.
+func (n *Notifier) deliverSinks() {
+	defer n.sinkWG.Done()
+	for d := range n.sinkJobs {
+		sinkErrs := deliver(d.payload, d.sinks)
+		n.finish(d, func() { d.sinkErrs = sinkErrs })
+	}
+}
+
+// prune снимает подписку с чатов, которые больше не могут получать сообщения, и переносит
+// подписку мигрировавших групп. Возвращает причины по чатам для журнала.
+func (n *Notifier) prune(errs map[int64]error) map[int64]string {
+	var pruned map[int64]string
+	for chatID, err := range errs {
+		reason, migrateTo, ok := delivery.Undeliverable(err)
+		if !ok {
+			continue
+		}
+		var storeErr error
+		if reason == delivery.ReasonMigrated {
+			reason = fmt.Sprintf("%s:%d", reason, migrateTo)
+			_, storeErr = n.subs.Migrate(chatID, migrateTo)
+			log.Printf("Чат %d стал супергруппой %d, подписка перенесена", chatID, migrateTo)
+		} else {
+			_, storeErr = n.subs.Unsubscribe(chatID)
+			log.Printf("Чат %d отписан: %s (%v)", chatID, reason, err)
+		}
+		if storeErr != nil {
+			log.Printf("Не удалось обновить подписчиков после ошибки чата %d: %v", chatID, storeErr)
+		}
+		if migrateTo != 0 {
+			n.moveChat(chatID, migrateTo)
+		} else {
+			n.forgetChat(chatID)
+		}
+		if pruned == nil {
+			pruned = make(map[int64]string)
+		}
+		pruned[chatID] = reason
+	}
+	return pruned
+}
+
+// forgetChat удаляет состояние дедупликации чата, которого больше нет среди подписчиков.
+func (n *Notifier) forgetChat(chatID int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prefix := fmt.Sprintf("%d|", chatID)
+	for key := range n.lastSignal {
+		if strings.HasPrefix(key, prefix) {
+			delete(n.lastSignal, key)
+			n.dirty = true
+		}
+	}
+	n.persistLocked()
+}
+
+// moveChat переносит состояние дедупликации группы на chat_id супергруппы, чтобы сигналы,
+// которые группа уже получила, не пришли ей повторно. Состояние, которое уже есть
+// у нового чата, сохраняется.
+func (n *Notifier) moveChat(from, to int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prefix := fmt.Sprintf("%d|", from)
+	for key, st := range n.lastSignal {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		delete(n.lastSignal, key)
+		moved := fmt.Sprintf("%d|%s", to, rest)
+		if _, taken := n.lastSignal[moved]; !taken {
+			n.lastSignal[moved] = st
+		}
+		n.dirty = true
+	}
+	n.persistLocked()
+}
+
+// deliver отправляет сигнал в каналы и возвращает ошибки доставки по именам каналов.
+func deliver(p Payload, sinks []Sink) map[string]error {
+	var errs map[string]error
+	for _, s := range sinks {
+		if err := s.Send(p); err != nil {
+			log.Printf("Не удалось отправить сигнал %s в канал %s: %v", p.Symbol, s.Name(), err)
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[s.Name()] = err
+		}
+	}
+	return errs
+}
+
+// renderChart рисует график сигнала; при ошибке сигнал уходит без картинки.
+func renderChart(sig Signal) []byte {
+	if sig.ChartBars <= 0 || len(sig.Candles) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	err := chart.Render(&buf, sig.Candles, chart.Options{
+		Title:       sig.Symbol + " " + sig.Timeframe,
+		Bars:        sig.ChartBars,
+		RSIPeriod:   sig.RSIPeriod,
+		StochPeriod: sig.StochPeriod,
+		SmoothK:     sig.SmoothK,
+		SmoothD:     sig.SmoothD,
+	})
+	if err != nil {
+		log.Printf("Не удалось нарисовать график %s: %v", sig.Symbol, err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// record сохраняет разосланный сигнал в журнал вместе с получателями и ошибками доставки.
+func (n *Notifier) record(sig Signal, mode string, chats []int64, errs map[int64]error, pruned map[int64]string, sinks []Sink, sinkErrs map[string]error) {
+	if n.history == nil {
 		return
 	}
+	r := history.Record{
+		SentAt:     time.Now(),
+		Symbol:     sig.Symbol,
+		Timeframe:  sig.Timeframe,
+		Mode:       mode,
+		BarTime:    sig.BarTime,
+		Price:      sig.Price,
+		RSI:        sig.Values.RSI,
+		RawK:       sig.Values.RawK,
+		K:          sig.Values.K,
+		D:          sig.Values.D,
+		Recipients: chats,
+		Pruned:     pruned,
+	}
+	for chatID, err := range errs {
+		if r.Errors == nil {
+			r.Errors = make(map[int64]string, len(errs))
+		}
+		r.Errors[chatID] = err.Error()
+	}
+	for _, s := range sinks {
+		r.Sinks = append(r.Sinks, s.Name())
+	}
+	for name, err := range sinkErrs {
+		if r.SinkErrors == nil {
+			r.SinkErrors = make(map[string]string, len(sinkErrs))
+		}
+		r.SinkErrors[name] = err.Error()
+	}
+	if err := n.history.Append(r); err != nil {
+		log.Printf("Не удалось записать сигнал %s в журнал: %v", sig.Symbol, err)
+	}
+}
+
+func formatSignal(sig Signal, mode string) string {
 	title := "🔴 *Upper RSI/Stoch RSI*"
-	if n.signalMode == "lower" {
+	if mode == subscribers.ModeLower {
 		title = "🟢 *Lower RSI/Stoch RSI*"
 	}
 	message := fmt.Sprintf("%s\n\nSymbol: `%s`\nRSI: *%.2f*\nStoch RSI %%K: *%.2f*\n\nТаймфрейм: %s\nRSI period: %d\nStoch period: %d\nSmoothing: %d/%d",
-		title, symbol, rsiValue, kValue, timeframe, rsiPeriod, stochPeriod, smoothK, smoothD)
-	n.broadcast(message, "Markdown")
+		title, sig.Symbol, sig.Values.RSI, sig.Values.K, sig.Timeframe, sig.RSIPeriod, sig.StochPeriod, sig.SmoothK, sig.SmoothD)
+	if !sig.BarTime.IsZero() {
+		message += "\nСвеча: " + sig.BarTime.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	return message
+}
+
+// broadcast ставит сообщение чатам в очередь sender и сразу возвращается; done получает
+// ошибки доставки по чатам, когда закончены все отправки. Если передан график, сообщение
+// уходит фото с подписью: картинка загружается один раз, после чего остальные чаты
+// получают её по file_id, а темп задаёт очередь.
+func (n *Notifier) broadcast(message, parseMode string, chartPNG []byte, chats []int64, done func(map[int64]error)) {
+	f := &fanout{sender: n.sender, message: message, parseMode: parseMode, left: len(chats), done: done}
+	if chartPNG != nil {
+		f.upload(chats, tgbotapi.FileBytes{Name: "chart.png", Bytes: chartPNG})
+		return
+	}
+	for _, chatID := range chats {
+		f.send(chatID, nil, nil)
+	}
+}
+
+// fanout — одно сообщение, которое рассылается нескольким чатам.
+type fanout struct {
+	sender    Sender
+	message   string
+	parseMode string
+	done      func(map[int64]error)
+
+	mu   sync.Mutex
+	left int
+	errs map[int64]error
+}
+
+// upload загружает график в первый чат из chats, а остальным отправляет его по file_id.
+// Если загрузка не удалась, график загружается в следующий чат.
+func (f *fanout) upload(chats []int64, photo tgbotapi.FileBytes) {
+	rest := chats[1:]
+	f.send(chats[0], photo, func(sent tgbotapi.Message, err error) {
+		if err != nil {
+			if len(rest) > 0 {
+				f.upload(rest, photo)
+			}
+			return
+		}
+		var file tgbotapi.RequestFileData = photo
+		if len(sent.Photo) > 0 {
+			file = tgbotapi.FileID(sent.Photo[len(sent.Photo)-1].FileID)
+		}
+		for _, chatID := range rest {
+			f.send(chatID, file, nil)
+		}
+	})
 }
 
-func (n *Notifier) broadcast(message, parseMode string) {
-	subs := n.getSubs()
-	for chatID := range subs {
-		msg := tgbotapi.NewMessage(chatID, message)
-		if parseMode != "" {
-			msg.ParseMode = parseMode
+// send ставит сообщение одному чату; then вызывается после учёта результата.
+func (f *fanout) send(chatID int64, photo tgbotapi.RequestFileData, then func(tgbotapi.Message, error)) {
+	var msg tgbotapi.Chattable
+	if photo != nil {
+		p := tgbotapi.NewPhoto(chatID, photo)
+		p.Caption = f.message
+		p.ParseMode = f.parseMode
+		msg = p
+	} else {
+		m := tgbotapi.NewMessage(chatID, f.message)
+		m.ParseMode = f.parseMode
+		msg = m
+	}
+	f.sender.Enqueue(chatID, msg, func(sent tgbotapi.Message, err error) {
+		f.result(chatID, err)
+		if then != nil {
+			then(sent, err)
 		}
-		if _, err := n.bot.Send(msg); err != nil {
-			log.Printf("Не удалось отправить сообщение %d: %v", chatID, err)
+	})
+}
+
+// result учитывает отправку в чат и, когда отправки закончены, передаёт ошибки в done.
+func (f *fanout) result(chatID int64, err error) {
+	f.mu.Lock()
+	if err != nil {
+		log.Printf("Не удалось отправить сообщение %d: %v", chatID, err)
+		if f.errs == nil {
+			f.errs = make(map[int64]error)
 		}
+		f.errs[chatID] = err
+	}
+	f.left--
+	last := f.left == 0
+	f.mu.Unlock()
+	if last {
+		f.done(f.errs)
 	}
 }
@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSignalStateSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signal_state.json")
+	bar := time.Now().Truncate(time.Hour)
+
+	before := New(nil, nil, nil)
+	if err := before.LoadState(path, 3); err != nil {
+		t.Fatalf("LoadState() error: %v", err)
+	}
+	if !before.ShouldSend(1, "BTCUSDT", "60", "upper", bar) {
+		t.Fatal("first signal should be sent")
+	}
+	if err := before.SaveState(); err != nil {
+		t.Fatalf("SaveState() error: %v", err)
+	}
+
+	after := New(nil, nil, nil)
+	if err := after.LoadState(path, 3); err != nil {
+		t.Fatalf("LoadState() after restart error: %v", err)
+	}
+	if after.ShouldSend(1, "BTCUSDT", "60", "upper", bar.Add(time.Hour)) {
+		t.Fatal("signal still in zone was re-sent after restart")
+	}
+	if !after.ShouldSend(1, "BTCUSDT", "60", "lower", bar.Add(time.Hour)) {
+		t.Fatal("switch to another zone should be sent")
+	}
+	// Зону не подтверждали дольше трёх часовых свечей — запись устарела.
+	if !after.ShouldSend(1, "BTCUSDT", "60", "lower", bar.Add(5*time.Hour)) {
+		t.Fatal("expired state should not suppress a new signal")
+	}
+}
+
+func TestLoadStateDropsExpiredEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signal_state.json")
+	old := time.Now().Add(-48 * time.Hour)
+
+	n := New(nil, nil, nil)
+	if err := n.LoadState(path, 3); err != nil {
+		t.Fatal(err)
+	}
+	n.ShouldSend(1, "ETHUSDT", "60", "upper", old)
+	if err := n.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := New(nil, nil, nil)
+	if err := reloaded.LoadState(path, 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.lastSignal) != 0 {
+		t.Fatalf("stale entries survived reload: %+v", reloaded.lastSignal)
+	}
+}
+
+// fakeSubs — подписчики в памяти.
+type fakeSubs struct {
+	subs map[int64]subscribers.Profile
+}
+
+func (f *fakeSubs) All() map[int64]subscribers.Profile { return maps.Clone(f.subs) }
+
+func (f *fakeSubs) Unsubscribe(chatID int64) (bool, error) {
+	_, ok := f.subs[chatID]
+	delete(f.subs, chatID)
+	return ok, nil
+}
+
+func (f *fakeSubs) Migrate(from, to int64) (bool, error) {
+	p, ok := f.subs[from]
+	if ok {
+		delete(f.subs, from)
+		f.subs[to] = p
+	}
+	return ok, nil
+}
+
+// fakeSender отвечает на отправку в чат заданной ошибкой. Пока release не закрыт,
+// отправки ждут, как сообщения в очереди Telegram.
+type fakeSender struct {
+	mu      sync.Mutex
+	errs    map[int64]error
+	sent    []int64
+	uploads []int64 // чаты, в которые график загружался файлом, а не по file_id
+	release chan struct{}
+}
+
+func (f *fakeSender) Enqueue(chatID int64, msg tgbotapi.Chattable, done func(tgbotapi.Message, error)) {
+	go func() {
+		if f.release != nil {
+			<-f.release
+		}
+		var reply tgbotapi.Message
+		f.mu.Lock()
+		if photo, ok := msg.(tgbotapi.PhotoConfig); ok {
+			if _, upload := photo.File.(tgbotapi.FileBytes); upload {
+				f.uploads = append(f.uploads, chatID)
+			}
+			reply.Photo = []tgbotapi.PhotoSize{{FileID: "chart"}}
+		}
+		err := f.errs[chatID]
+		if err == nil {
+			f.sent = append(f.sent, chatID)
+		}
+		f.mu.Unlock()
+		done(reply, err)
+	}()
+}
+
+func TestSendSignalPrunesUnreachableChats(t *testing.T) {
+	profile := subscribers.Profile{Mode: subscribers.ModeUpper, Timeframes: []string{"60"}, RSIUpper: 70, StochUpper: 80}
+	subs := &fakeSubs{subs: map[int64]subscribers.Profile{1: profile, 2: profile, 3: profile, 4: profile, 5: profile}}
+	sender := &fakeSender{errs: map[int64]error{
+		2: &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"},
+		3: &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"},
+		4: &tgbotapi.Error{Code: 400, Message: "Bad Request: group chat was upgraded to a supergroup chat", ResponseParameters: tgbotapi.ResponseParameters{MigrateToChatID: -1004}},
+		5: &tgbotapi.Error{Code: 400, Message: "Bad Request: can't parse entities"},
+	}}
+	hist := history.New(filepath.Join(t.TempDir(), "signals.jsonl"), 0, 0)
+	n := New(sender, subs, hist)
+
+	sig := Signal{Symbol: "BTCUSDT", Timeframe: "60", BarTime: time.Now().Truncate(time.Hour), Values: rsi.StochRSIValues{RSI: 75, K: 90}}
+	if !n.SendSignal(sig) {
+		t.Fatal("SendSignal() = false")
+	}
+	n.Close()
+
+	want := []int64{-1004, 1, 5}
+	got := slices.Sorted(maps.Keys(subs.subs))
+	if !slices.Equal(got, want) {
+		t.Fatalf("subscribers after prune = %v, want %v", got, want)
+	}
+	records, err := hist.Query(history.Query{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("history = %+v, %v", records, err)
+	}
+	pruned := records[0].Pruned
+	if len(pruned) != 3 || pruned[2] != "blocked" || pruned[3] != "chat_not_found" || pruned[4] != "migrated:-1004" {
+		t.Fatalf("pruned = %v", pruned)
+	}
+	// Супергруппа наследует состояние дедупликации группы, иначе тот же сигнал придёт повторно.
+	for chatID, want := range map[int64]bool{1: true, 2: false, 3: false, 4: false, -1004: true} {
+		if _, ok := n.lastSignal[signalKey(chatID, "BTCUSDT", "60")]; ok != want {
+			t.Fatalf("dedup state of chat %d present = %v, want %v", chatID, ok, want)
+		}
+	}
+	if n.SendSignal(sig) {
+		t.Fatal("the migrated chat got the same signal again")
+	}
+}
+
+func TestSendSignalDoesNotWaitForChats(t *testing.T) {
+	profile := subscribers.Profile{Mode: subscribers.ModeUpper, Timeframes: []string{"60"}, RSIUpper: 70, StochUpper: 80}
+	subs := &fakeSubs{subs: map[int64]subscribers.Profile{1: profile, 2: profile}}
+	sender := &fakeSender{release: make(chan struct{})}
+	hist := history.New(filepath.Join(t.TempDir(), "signals.jsonl"), 0, 0)
+	n := New(sender, subs, hist)
+
+	done := make(chan bool)
+	go func() {
+		done <- n.SendSignal(Signal{Symbol: "BTCUSDT", Timeframe: "60", BarTime: time.Now(), Values: rsi.StochRSIValues{RSI: 75, K: 90}})
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("SendSignal() = false, want the signal queued")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendSignal() waited for the Telegram queue")
+	}
+	if records, _ := hist.Query(history.Query{}); len(records) != 0 {
+		t.Fatalf("history = %+v before delivery finished", records)
+	}
+
+	close(sender.release)
+	n.Close()
+	records, err := hist.Query(history.Query{})
+	if err != nil || len(records) != 1 || len(records[0].Recipients) != 2 {
+		t.Fatalf("history = %+v, %v, want one record after delivery", records, err)
+	}
+}
+
+func TestBroadcastUploadsChartOnce(t *testing.T) {
+	sender := &fakeSender{errs: map[int64]error{1: &tgbotapi.Error{Message: "Bad Gateway"}}}
+	n := New(sender, nil, nil)
+
+	got := make(chan map[int64]error, 1)
+	n.broadcast("signal", "Markdown", []byte("\x89PNG"), []int64{1, 2, 3, 4}, func(errs map[int64]error) { got <- errs })
+	errs := <-got
+	if len(errs) != 1 || errs[1] == nil {
+		t.Fatalf("errs = %v, want only chat 1 failed", errs)
+	}
+	// Загрузка в чат 1 не удалась, поэтому график загружается в чат 2, а 3 и 4 получают file_id.
+	if !slices.Equal(sender.uploads, []int64{1, 2}) || len(sender.sent) != 3 {
+		t.Fatalf("uploads = %v, sent = %v", sender.uploads, sender.sent)
+	}
+}
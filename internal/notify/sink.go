@@ -0,0 +1,333 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Payload — сигнал в виде, одинаковом для всех каналов доставки.
+type Payload struct {
+	Symbol      string    `json:"symbol"`
+	Timeframe   string    `json:"timeframe"`
+	Mode        string    `json:"mode"`
+	BarTime     time.Time `json:"bar_time"`
+	Price       float64   `json:"price"`
+	RSI         float64   `json:"rsi"`
+	RawK        float64   `json:"raw_k"`
+	K           float64   `json:"k"`
+	D           float64   `json:"d"`
+	RSIPeriod   int       `json:"rsi_period"`
+	StochPeriod int       `json:"stoch_period"`
+	SmoothK     int       `json:"smooth_k"`
+	SmoothD     int       `json:"smooth_d"`
+	Text        string    `json:"text"` // текст уведомления в Markdown Telegram
+	Chart       []byte    `json:"-"`    // PNG-график или nil
+}
+
+func newPayload(sig Signal, mode string, chartPNG []byte) Payload {
+	return Payload{
+		Symbol:      sig.Symbol,
+		Timeframe:   sig.Timeframe,
+		Mode:        mode,
+		BarTime:     sig.BarTime,
+		Price:       sig.Price,
+		RSI:         sig.Values.RSI,
+		RawK:        sig.Values.RawK,
+		K:           sig.Values.K,
+		D:           sig.Values.D,
+		RSIPeriod:   sig.RSIPeriod,
+		StochPeriod: sig.StochPeriod,
+		SmoothK:     sig.SmoothK,
+		SmoothD:     sig.SmoothD,
+		Text:        formatSignal(sig, mode),
+		Chart:       chartPNG,
+	}
+}
+
+// PlainText возвращает текст уведомления без разметки.
+func (p Payload) PlainText() string {
+	return strings.NewReplacer("*", "", "`", "").Replace(p.Text)
+}
+
+// Sink — канал доставки сигналов помимо подписчиков бота.
+type Sink interface {
+	// Name возвращает имя канала из конфига.
+	Name() string
+	// Send доставляет сигнал.
+	Send(p Payload) error
+}
+
+// NewSink создаёт канал по конфигу; sender нужен только каналам telegram.
+func NewSink(c config.SinkConfig, sender Sender) (Sink, error) {
+	switch c.Type {
+	case config.SinkTelegram:
+		return &TelegramSink{name: c.Name, sender: sender, chatID: c.ChatID}, nil
+	case config.SinkDiscord:
+		return &DiscordSink{name: c.Name, url: c.URL}, nil
+	case config.SinkSlack:
+		return &SlackSink{name: c.Name, url: c.URL}, nil
+	case config.SinkWebhook:
+		return &WebhookSink{name: c.Name, url: c.URL, headers: c.Headers}, nil
+	case config.SinkEmail:
+		port := c.SMTPPort
+		if port == 0 {
+			port = 587
+		}
+		return &EmailSink{
+			name:     c.Name,
+			addr:     c.SMTPHost + ":" + strconv.Itoa(port),
+			host:     c.SMTPHost,
+			username: c.Username,
+			password: c.Password,
+			from:     c.From,
+			to:       c.To,
+			timeout:  emailTimeout,
+		}, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип канала %q", c.Type)
+}
+
+// TelegramSink публикует сигналы в один чат, например канал или группу команды.
+type TelegramSink struct {
+	name   string
+	sender Sender
+	chatID int64
+}
+
+func (s *TelegramSink) Name() string { return s.name }
+
+func (s *TelegramSink) Send(p Payload) error {
+	var msg tgbotapi.Chattable
+	if p.Chart != nil {
+		photo := tgbotapi.NewPhoto(s.chatID, tgbotapi.FileBytes{Name: "chart.png", Bytes: p.Chart})
+		photo.Caption = p.Text
+		photo.ParseMode = "Markdown"
+		msg = photo
+	} else {
+		m := tgbotapi.NewMessage(s.chatID, p.Text)
+		m.ParseMode = "Markdown"
+		msg = m
+	}
+	done := make(chan error, 1)
+	s.sender.Enqueue(s.chatID, msg, func(_ tgbotapi.Message, err error) { done <- err })
+	return <-done
+}
+
+// DiscordSink отправляет сигналы во входящий вебхук Discord; график прикладывается файлом.
+type DiscordSink struct {
+	name string
+	url  string
+}
+
+func (s *DiscordSink) Name() string { return s.name }
+
+func (s *DiscordSink) Send(p Payload) error {
+	// В Discord жирный шрифт — **текст**, моноширинный `текст` совпадает с Telegram.
+	body, err := json.Marshal(map[string]string{"content": strings.ReplaceAll(p.Text, "*", "**")})
+	if err != nil {
+		return err
+	}
+	if p.Chart == nil {
+		return post(s.url, "application/json", bytes.NewReader(body), nil)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("payload_json", string(body)); err != nil {
+		return err
+	}
+	part, err := w.CreateFormFile("files[0]", "chart.png")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(p.Chart); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return post(s.url, w.FormDataContentType(), &buf, nil)
+}
+
+// SlackSink отправляет сигналы во входящий вебхук Slack. Вебхук не принимает файлы,
+// поэтому график не прикладывается.
+type SlackSink struct {
+	name string
+	url  string
+}
+
+func (s *SlackSink) Name() string { return s.name }
+
+func (s *SlackSink) Send(p Payload) error {
+	// Разметка mrkdwn Slack для *жирного* и `кода` совпадает с Markdown Telegram.
+	body, err := json.Marshal(map[string]string{"text": p.Text})
+	if err != nil {
+		return err
+	}
+	return post(s.url, "application/json", bytes.NewReader(body), nil)
+}
+
+// WebhookSink отправляет Payload в JSON на произвольный адрес; график — PNG в base64.
+type WebhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+}
+
+func (s *WebhookSink) Name() string { return s.name }
+
+func (s *WebhookSink) Send(p Payload) error {
+	body, err := json.Marshal(struct {
+		Payload
+		ChartPNG string `json:"chart_png,omitempty"`
+	}{p, base64.StdEncoding.EncodeToString(p.Chart)})
+	if err != nil {
+		return err
+	}
+	return post(s.url, "application/json", bytes.NewReader(body), s.headers)
+}
+
+// emailTimeout — сколько может длиться отправка письма целиком, от подключения до QUIT.
+const emailTimeout = 30 * time.Second
+
+// EmailSink отправляет сигналы письмом через SMTP; график — вложением.
+type EmailSink struct {
+	name     string
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	timeout  time.Duration
+}
+
+func (s *EmailSink) Name() string { return s.name }
+
+// Send повторяет smtp.SendMail, но с ограничением времени на подключение и на весь
+// разговор с сервером: зависший почтовый сервер не должен держать доставку вечно.
+func (s *EmailSink) Send(p Payload) error {
+	msg, err := buildEmail(s.from, s.to, p)
+	if err != nil {
+		return err
+	}
+	conn, err := (&net.Dialer{Timeout: s.timeout}).Dial("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildEmail собирает письмо MIME: текст сигнала и, если есть, PNG-график вложением.
+func buildEmail(from string, to []string, p Payload) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	subject := fmt.Sprintf("%s %s %s RSI %.2f", strings.ToUpper(p.Mode), p.Symbol, p.Timeframe, p.RSI)
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, strings.Join(to, ", "), subject, w.Boundary())
+
+	part, err := w.CreatePart(map[string][]string{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(part, p.PlainText()); err != nil {
+		return nil, err
+	}
+	if p.Chart != nil {
+		part, err := w.CreatePart(map[string][]string{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {`attachment; filename="chart.png"`},
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, part)
+		if _, err := enc.Write(p.Chart); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var sinkClient = &http.Client{Timeout: 10 * time.Second}
+
+// post отправляет запрос вебхуку и считает ошибкой любой ответ вне 2xx.
+func post(url, contentType string, body io.Reader, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := sinkClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/config"
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/rsi"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+type capturedRequest struct {
+	contentType string
+	header      http.Header
+	body        []byte
+}
+
+func captureServer(t *testing.T, status int) (*httptest.Server, *[]capturedRequest) {
+	t.Helper()
+	var got []capturedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, capturedRequest{contentType: r.Header.Get("Content-Type"), header: r.Header.Clone(), body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func testPayload() Payload {
+	sig := Signal{Symbol: "BTCUSDT", Timeframe: "60", Values: rsi.StochRSIValues{RSI: 75.5, K: 91}, RSIPeriod: 14, StochPeriod: 14, SmoothK: 3, SmoothD: 3}
+	return newPayload(sig, subscribers.ModeUpper, nil)
+}
+
+func TestWebhookSinks(t *testing.T) {
+	srv, got := captureServer(t, http.StatusNoContent)
+	p := testPayload()
+
+	for _, c := range []config.SinkConfig{
+		{Type: config.SinkDiscord, Name: "discord", URL: srv.URL},
+		{Type: config.SinkSlack, Name: "slack", URL: srv.URL},
+		{Type: config.SinkWebhook, Name: "hook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+	} {
+		sink, err := NewSink(c, nil)
+		if err != nil {
+			t.Fatalf("NewSink(%s) error: %v", c.Type, err)
+		}
+		if err := sink.Send(p); err != nil {
+			t.Fatalf("%s Send() error: %v", c.Type, err)
+		}
+	}
+	if len(*got) != 3 {
+		t.Fatalf("got %d requests, want 3", len(*got))
+	}
+
+	var discord map[string]string
+	if err := json.Unmarshal((*got)[0].body, &discord); err != nil || !strings.Contains(discord["content"], "**Upper RSI/Stoch RSI**") {
+		t.Fatalf("discord body = %s, want bold converted to **", (*got)[0].body)
+	}
+	var slack map[string]string
+	if err := json.Unmarshal((*got)[1].body, &slack); err != nil || slack["text"] != p.Text {
+		t.Fatalf("slack body = %s", (*got)[1].body)
+	}
+	var hook Payload
+	if err := json.Unmarshal((*got)[2].body, &hook); err != nil || hook.Symbol != "BTCUSDT" || hook.Mode != "upper" || hook.RSI != 75.5 {
+		t.Fatalf("webhook body = %s", (*got)[2].body)
+	}
+	if auth := (*got)[2].header.Get("Authorization"); auth != "Bearer secret" {
+		t.Fatalf("webhook Authorization = %q", auth)
+	}
+}
+
+func TestWebhookSinkReportsHTTPError(t *testing.T) {
+	srv, _ := captureServer(t, http.StatusBadRequest)
+	sink, _ := NewSink(config.SinkConfig{Type: config.SinkSlack, Name: "slack", URL: srv.URL}, nil)
+	if err := sink.Send(testPayload()); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("Send() error = %v, want http 400", err)
+	}
+}
+
+func TestBuildEmailAttachesChart(t *testing.T) {
+	p := testPayload()
+	p.Chart = []byte("\x89PNG")
+	msg, err := buildEmail("bot@example.com", []string{"a@example.com", "b@example.com"}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(msg)
+	for _, want := range []string{"To: a@example.com, b@example.com", "Subject: UPPER BTCUSDT 60 RSI 75.50", "Symbol: BTCUSDT", "image/png", "iVBORw=="} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("email missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "*Upper") {
+		t.Fatalf("email body kept Markdown:\n%s", text)
+	}
+}
+
+type fakeSink struct {
+	name string
+	sent []Payload
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Send(p Payload) error {
+	s.sent = append(s.sent, p)
+	return nil
+}
+
+func TestSendSignalRoutesToSinks(t *testing.T) {
+	hist := history.New(filepath.Join(t.TempDir(), "signals.jsonl"), 0, 0)
+	n := New(nil, &fakeSubs{}, hist)
+	upper := &fakeSink{name: "upper"}
+	lower := &fakeSink{name: "lower"}
+	profile := subscribers.Profile{Timeframes: []string{"60"}, RSIUpper: 70, RSILower: 30, StochUpper: 80, StochLower: 20}
+	profile.Mode = subscribers.ModeUpper
+	n.AddRoute(Route{Sink: upper, Profile: profile})
+	profile.Mode = subscribers.ModeLower
+	n.AddRoute(Route{Sink: lower, Profile: profile})
+
+	bar := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sig := Signal{Symbol: "BTCUSDT", Timeframe: "60", BarTime: bar, Values: rsi.StochRSIValues{RSI: 75, RawK: 95, K: 90}}
+	if !n.SendSignal(sig) {
+		t.Fatal("SendSignal() = false, want delivery to sink")
+	}
+	sig.BarTime = bar.Add(time.Hour)
+	if n.SendSignal(sig) {
+		t.Fatal("repeated signal in the same zone was delivered again")
+	}
+	n.Close()
+	if len(upper.sent) != 1 || len(lower.sent) != 0 || upper.sent[0].Mode != "upper" {
+		t.Fatalf("upper sent %d, lower sent %d", len(upper.sent), len(lower.sent))
+	}
+
+	records, err := hist.Query(history.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Sinks) != 1 || records[0].Sinks[0] != "upper" {
+		t.Fatalf("history = %+v, want one record with sink upper", records)
+	}
+}
+
+// blockingSink не возвращается из Send, пока не закрыт release.
+type blockingSink struct {
+	release chan struct{}
+	sent    chan string
+}
+
+func (s *blockingSink) Name() string { return "slow" }
+
+func (s *blockingSink) Send(p Payload) error {
+	<-s.release
+	s.sent <- p.Symbol
+	return nil
+}
+
+func TestSendSignalDoesNotWaitForSinks(t *testing.T) {
+	hist := history.New(filepath.Join(t.TempDir(), "signals.jsonl"), 0, 0)
+	n := New(nil, &fakeSubs{}, hist)
+	sink := &blockingSink{release: make(chan struct{}), sent: make(chan string, 1)}
+	profile := subscribers.Profile{Mode: subscribers.ModeUpper, Timeframes: []string{"60"}, RSIUpper: 70, RSILower: 30, StochUpper: 80, StochLower: 20}
+	n.AddRoute(Route{Sink: sink, Profile: profile})
+
+	done := make(chan bool)
+	go func() {
+		done <- n.SendSignal(Signal{Symbol: "BTCUSDT", Timeframe: "60", BarTime: time.Now(), Values: rsi.StochRSIValues{RSI: 75, RawK: 95, K: 90}})
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("SendSignal() = false, want the sink signal queued")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendSignal() waited for a slow sink")
+	}
+
+	close(sink.release)
+	n.Close()
+	if got := <-sink.sent; got != "BTCUSDT" {
+		t.Fatalf("sink got %q", got)
+	}
+	records, err := hist.Query(history.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Sinks) != 1 || records[0].SinkErrors != nil {
+		t.Fatalf("history = %+v, want the record written after delivery", records)
+	}
+}
+
+func TestEmailSinkTimesOut(t *testing.T) {
+	// Сервер принимает соединение, но не отвечает приветствием.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 1)
+	t.Cleanup(func() {
+		ln.Close()
+		select {
+		case conn := <-conns:
+			conn.Close()
+		default:
+		}
+	})
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conns <- conn
+		}
+	}()
+
+	sink := &EmailSink{name: "mail", addr: ln.Addr().String(), host: "127.0.0.1", from: "bot@example.com", to: []string{"a@example.com"}, timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := sink.Send(testPayload()); err == nil {
+		t.Fatal("Send() = nil from a server that never answered")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Send() returned after %v, want the timeout of 100ms", elapsed)
+	}
+}
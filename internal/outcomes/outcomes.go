@@ -0,0 +1,337 @@
+// Package outcomes следит за ценой после разосланных сигналов: для каждого горизонта
+// в N свечей записывает доходность close-to-close и максимальные движения в сторону
+// сигнала и против него, а затем сводит их в статистику попаданий.
+package outcomes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/history"
+	"grevtsevalex/crypto-bot/internal/subscribers"
+)
+
+const (
+	// maxFetch — сколько последних свечей можно запросить у любой из бирж за раз (у OKX — 300).
+	maxFetch = 300
+	// retention — сколько хранить завершённые результаты.
+	retention = 180 * 24 * time.Hour
+)
+
+// Result — поведение цены через Bars свечей после сигнала, в процентах от цены сигнала.
+type Result struct {
+	Bars   int     `json:"bars"`
+	Return float64 `json:"return_pct"` // изменение цены закрытия
+	MFE    float64 `json:"mfe_pct"`    // максимальное движение в сторону сигнала
+	MAE    float64 `json:"mae_pct"`    // максимальное движение против сигнала
+}
+
+// Hit сообщает, пошла ли цена в ожидаемую сторону: вниз после верхней зоны, вверх после нижней.
+func (r Result) Hit(mode string) bool {
+	if mode == subscribers.ModeLower {
+		return r.Return > 0
+	}
+	return r.Return < 0
+}
+
+// Outcome — отслеживаемый сигнал и результаты по созревшим горизонтам.
+type Outcome struct {
+	Symbol    string    `json:"symbol"`
+	Timeframe string    `json:"timeframe"`
+	Mode      string    `json:"mode"`
+	BarTime   time.Time `json:"bar_time"`
+	Price     float64   `json:"price"`
+	Results   []Result  `json:"results,omitempty"`
+	Done      bool      `json:"done"` // все горизонты посчитаны или история уже недоступна
+}
+
+func (o *Outcome) key() string {
+	return fmt.Sprintf("%s|%s|%s|%d", o.Symbol, o.Timeframe, o.Mode, o.BarTime.UnixMilli())
+}
+
+func (o *Outcome) result(bars int) (Result, bool) {
+	for _, r := range o.Results {
+		if r.Bars == bars {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// Tracker берёт новые сигналы из журнала бота, дозапрашивает свечи по мере созревания
+// горизонтов и хранит результаты в JSON-файле.
+type Tracker struct {
+	ex       exchange.Exchange
+	history  *history.Store
+	horizons []int
+	path     string
+
+	mu     sync.Mutex
+	items  map[string]*Outcome
+	cursor time.Time // время отправки последней учтённой записи журнала
+}
+
+type savedState struct {
+	Cursor   time.Time  `json:"cursor"`
+	Outcomes []*Outcome `json:"outcomes"`
+}
+
+// NewTracker создаёт трекер сигналов из журнала hist с результатами в файле path.
+func NewTracker(ex exchange.Exchange, hist *history.Store, horizons []int, path string) *Tracker {
+	return &Tracker{
+		ex:       ex,
+		history:  hist,
+		horizons: append([]int(nil), horizons...),
+		path:     path,
+		items:    make(map[string]*Outcome),
+	}
+}
+
+// Load читает сохранённые результаты.
+func (t *Tracker) Load() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var saved savedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%s: %w", t.path, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cursor = saved.Cursor
+	for _, o := range saved.Outcomes {
+		t.items[o.key()] = o
+	}
+	return nil
+}
+
+func (t *Tracker) saveLocked() error {
+	saved := savedState{Cursor: t.cursor, Outcomes: make([]*Outcome, 0, len(t.items))}
+	for _, o := range t.items {
+		saved.Outcomes = append(saved.Outcomes, o)
+	}
+	sort.Slice(saved.Outcomes, func(i, j int) bool { return saved.Outcomes[i].BarTime.Before(saved.Outcomes[j].BarTime) })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0644)
+}
+
+// Update подхватывает новые сигналы из журнала и считает горизонты, свечи которых уже закрылись к now.
+func (t *Tracker) Update(now time.Time) error {
+	records, err := t.history.Query(history.Query{Since: t.lastCursor()})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	changed := false
+	for _, r := range records {
+		o := &Outcome{Symbol: r.Symbol, Timeframe: r.Timeframe, Mode: r.Mode, BarTime: r.BarTime, Price: r.Price}
+		if _, ok := t.items[o.key()]; !ok {
+			t.items[o.key()] = o
+			changed = true
+		}
+		if r.SentAt.After(t.cursor) {
+			t.cursor = r.SentAt
+		}
+	}
+	var pending []*Outcome
+	for key, o := range t.items {
+		if o.Done && now.Sub(o.BarTime) > retention {
+			delete(t.items, key)
+			changed = true
+			continue
+		}
+		if !o.Done && t.matured(o, now) {
+			pending = append(pending, o)
+		}
+	}
+	t.mu.Unlock()
+
+	// Свечи запрашиваются без блокировки, чтобы /stats не ждал сеть.
+	for _, o := range pending {
+		results, done, err := t.evaluate(o, now)
+		if err != nil {
+			log.Printf("[outcomes] %s %s: %v", o.Symbol, o.Timeframe, err)
+			continue
+		}
+		t.mu.Lock()
+		o.Results = results
+		o.Done = done
+		changed = true
+		t.mu.Unlock()
+	}
+
+	if !changed {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
+
+func (t *Tracker) lastCursor() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cursor
+}
+
+// closedAfter возвращает число закрытых свечей после свечи сигнала к моменту now.
+func closedAfter(o *Outcome, now time.Time) int {
+	d := exchange.TimeframeDuration(o.Timeframe)
+	if d <= 0 {
+		return 0
+	}
+	return int(now.Sub(o.BarTime)/d) - 1
+}
+
+// matured сообщает, что закрылся хотя бы один ещё не посчитанный горизонт.
+func (t *Tracker) matured(o *Outcome, now time.Time) bool {
+	closed := closedAfter(o, now)
+	for _, h := range t.horizons {
+		if _, ok := o.result(h); !ok && h <= closed {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate запрашивает свечи от свечи сигнала до текущей и считает созревшие горизонты.
+// done=true, когда посчитаны все горизонты или свеча сигнала уже не помещается в запрос.
+func (t *Tracker) evaluate(o *Outcome, now time.Time) ([]Result, bool, error) {
+	limit := closedAfter(o, now) + 2 // свеча сигнала, закрытые после неё и текущая
+	if limit > maxFetch {
+		return o.Results, true, nil
+	}
+	candles, err := t.ex.Candles(o.Symbol, o.Timeframe, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := o.Price
+	var after []exchange.Candle
+	for _, c := range exchange.ClosedCandles(candles) {
+		switch {
+		case c.OpenTime.Equal(o.BarTime) && entry == 0:
+			entry = c.Close
+		case c.OpenTime.After(o.BarTime):
+			after = append(after, c)
+		}
+	}
+	if entry == 0 {
+		return o.Results, true, nil
+	}
+
+	results := append([]Result(nil), o.Results...)
+	for _, h := range t.horizons {
+		if _, ok := o.result(h); ok || h > len(after) {
+			continue
+		}
+		results = append(results, measure(o.Mode, entry, after[:h]))
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Bars < results[j].Bars })
+	return results, len(results) >= len(t.horizons), nil
+}
+
+// measure считает результат по свечам горизонта.
+func measure(mode string, entry float64, bars []exchange.Candle) Result {
+	high, low := bars[0].High, bars[0].Low
+	for _, b := range bars[1:] {
+		high = max(high, b.High)
+		low = min(low, b.Low)
+	}
+	up := (high/entry - 1) * 100
+	down := (1 - low/entry) * 100
+	r := Result{Bars: len(bars), Return: (bars[len(bars)-1].Close/entry - 1) * 100}
+	if mode == subscribers.ModeLower {
+		r.MFE, r.MAE = max(up, 0), max(down, 0)
+	} else {
+		r.MFE, r.MAE = max(down, 0), max(up, 0)
+	}
+	return r
+}
+
+// Stat — сводка по сигналам одного таймфрейма и режима на одном горизонте.
+type Stat struct {
+	Timeframe string
+	Mode      string
+	Bars      int
+	Count     int
+	Hits      int
+	AvgReturn float64
+	AvgMFE    float64
+	AvgMAE    float64
+}
+
+// HitRate возвращает долю попаданий в процентах.
+func (s Stat) HitRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Count) * 100
+}
+
+// Stats сводит посчитанные горизонты сигналов со свечой не раньше since
+// по таймфреймам, режимам и горизонтам.
+func (t *Tracker) Stats(since time.Time) []Stat {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	type key struct {
+		timeframe, mode string
+		bars            int
+	}
+	acc := make(map[key]*Stat)
+	for _, o := range t.items {
+		if o.BarTime.Before(since) {
+			continue
+		}
+		for _, r := range o.Results {
+			k := key{o.Timeframe, o.Mode, r.Bars}
+			st, ok := acc[k]
+			if !ok {
+				st = &Stat{Timeframe: o.Timeframe, Mode: o.Mode, Bars: r.Bars}
+				acc[k] = st
+			}
+			st.Count++
+			if r.Hit(o.Mode) {
+				st.Hits++
+			}
+			st.AvgReturn += r.Return
+			st.AvgMFE += r.MFE
+			st.AvgMAE += r.MAE
+		}
+	}
+
+	stats := make([]Stat, 0, len(acc))
+	for _, st := range acc {
+		n := float64(st.Count)
+		st.AvgReturn /= n
+		st.AvgMFE /= n
+		st.AvgMAE /= n
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Timeframe != b.Timeframe {
+			return exchange.TimeframeDuration(a.Timeframe) < exchange.TimeframeDuration(b.Timeframe)
+		}
+		if a.Mode != b.Mode {
+			return a.Mode > b.Mode // upper раньше lower
+		}
+		return a.Bars < b.Bars
+	})
+	return stats
+}
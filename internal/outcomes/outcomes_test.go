@@ -0,0 +1,83 @@
+package outcomes
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/history"
+)
+
+// fakeExchange отдаёт последние limit свечей из заранее заданного ряда.
+type fakeExchange struct {
+	candles []exchange.Candle
+	calls   int
+}
+
+func (f *fakeExchange) Name() string                       { return "fake" }
+func (f *fakeExchange) DerivativePairs() ([]string, error) { return nil, nil }
+func (f *fakeExchange) ServerTime() (time.Time, error)     { return time.Now(), nil }
+
+func (f *fakeExchange) Candles(symbol, timeframe string, limit int) ([]exchange.Candle, error) {
+	f.calls++
+	if limit > len(f.candles) {
+		limit = len(f.candles)
+	}
+	return f.candles[len(f.candles)-limit:], nil
+}
+
+func TestTrackerMeasuresHorizonsAndAggregates(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Свеча сигнала (0) и четыре закрытые после неё; пятая ещё формируется.
+	ex := &fakeExchange{}
+	for i, p := range []struct{ high, low, close float64 }{
+		{101, 99, 100}, {103, 98, 99}, {100, 95, 96}, {99, 94, 98}, {105, 97, 104}, {110, 100, 108},
+	} {
+		ex.candles = append(ex.candles, exchange.Candle{
+			OpenTime: start.Add(time.Duration(i) * time.Hour), High: p.high, Low: p.low, Close: p.close, Confirmed: i < 5,
+		})
+	}
+
+	hist := history.New(filepath.Join(dir, "signals.jsonl"), 0, 0)
+	if err := hist.Append(history.Record{SentAt: start.Add(time.Hour), Symbol: "BTCUSDT", Timeframe: "60", Mode: "upper", BarTime: start, Price: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "outcomes.json")
+	tr := NewTracker(ex, hist, []int{1, 4, 12}, path)
+	now := start.Add(5*time.Hour + time.Minute)
+	if err := tr.Update(now); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	stats := tr.Stats(time.Time{})
+	if len(stats) != 2 {
+		t.Fatalf("Stats() = %+v, want horizons 1 and 4", stats)
+	}
+	one, four := stats[0], stats[1]
+	if one.Bars != 1 || one.Hits != 1 || math.Abs(one.AvgReturn+1) > 1e-9 || math.Abs(one.AvgMFE-2) > 1e-9 || math.Abs(one.AvgMAE-3) > 1e-9 {
+		t.Fatalf("1-bar stat = %+v, want return -1%%, MFE 2%%, MAE 3%%", one)
+	}
+	if four.Bars != 4 || four.Hits != 0 || math.Abs(four.AvgReturn-4) > 1e-9 || math.Abs(four.AvgMFE-6) > 1e-9 || math.Abs(four.AvgMAE-5) > 1e-9 {
+		t.Fatalf("4-bar stat = %+v, want return +4%%, MFE 6%%, MAE 5%%", four)
+	}
+
+	// Без новых созревших горизонтов свечи не запрашиваются; состояние переживает перезапуск.
+	calls := ex.calls
+	reloaded := NewTracker(ex, hist, []int{1, 4, 12}, path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if err := reloaded.Update(now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if ex.calls != calls || len(reloaded.Stats(time.Time{})) != 2 {
+		t.Fatalf("reloaded tracker refetched (%d calls) or lost results: %+v", ex.calls-calls, reloaded.Stats(time.Time{}))
+	}
+	if got := reloaded.Stats(start.Add(time.Hour)); len(got) != 0 {
+		t.Fatalf("Stats(since after signal) = %+v, want none", got)
+	}
+}
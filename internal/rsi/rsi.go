@@ -1,13 +1,21 @@
 // Package rsi содержит расчёт RSI (по Уайлдеру, как на Bybit/TradingView) и Stochastic RSI.
 package rsi
 
+import "math"
+
 // StochRSIValues хранит последние значения осцилляторов в формате,
-// близком к отображению на графиках Bybit/TradingView.
+// близком к отображению на графиках Bybit/TradingView, и значения на предыдущем баре
+// (для пересечений вроде «%K пересёк %D снизу»).
 type StochRSIValues struct {
 	RSI  float64
 	RawK float64
 	K    float64
 	D    float64
+
+	PrevRSI  float64
+	PrevRawK float64
+	PrevK    float64
+	PrevD    float64
 }
 
 // RSI по Уайлдеру: первый RSI по SMA за period баров, далее сглаживание
@@ -113,37 +121,80 @@ func stoch(values []float64, period int) []float64 {
 	return out
 }
 
-// CalcStochRSI считает Stochastic RSI в формате Bybit/TradingView:
-// 1) RSI по Уайлдеру.
-// 2) Raw Stoch RSI = Stoch(RSI, RSI, RSI, stochPeriod).
-// 3) %K = SMA(raw, smoothK), %D = SMA(%K, smoothD).
-// closes — цены закрытия, старые → новые. Классические значения: 14/14/3/3.
-func CalcStochRSI(closes []float64, rsiPeriod, stochPeriod, smoothK, smoothD int) StochRSIValues {
-	rsiSeries := rsiWilder(closes, rsiPeriod)
-	if len(rsiSeries) == 0 {
-		return StochRSIValues{}
-	}
+// Series — ряды индикаторов, выровненные по ценам закрытия: i-е значение относится к closes[i].
+// Пока индикатору не хватает истории, значение — NaN.
+type Series struct {
+	RSI  []float64
+	RawK []float64
+	K    []float64
+	D    []float64
+}
 
+// RSISeries возвращает RSI по Уайлдеру на каждом баре; первые period значений — NaN.
+func RSISeries(closes []float64, period int) []float64 {
+	return align(rsiWilder(closes, period), len(closes))
+}
+
+// StochRSISeries считает RSI, raw Stoch RSI, %K и %D на каждом баре, как CalcStochRSI.
+// RSI определён с бара rsiPeriod, raw — ещё через stochPeriod-1 баров, %K и %D — ещё через
+// smoothK-1 и smoothD-1 баров соответственно.
+func StochRSISeries(closes []float64, rsiPeriod, stochPeriod, smoothK, smoothD int) Series {
+	rsiSeries := rsiWilder(closes, rsiPeriod)
 	rawSeries := stoch(rsiSeries, stochPeriod)
 	kSeries := sma(rawSeries, smoothK)
 	dSeries := sma(kSeries, smoothD)
+	n := len(closes)
+	return Series{
+		RSI:  align(rsiSeries, n),
+		RawK: align(rawSeries, n),
+		K:    align(kSeries, n),
+		D:    align(dSeries, n),
+	}
+}
+
+// align дополняет ряд спереди NaN до длины n: все ряды заканчиваются на последней цене.
+func align(values []float64, n int) []float64 {
+	out := make([]float64, n)
+	pad := n - len(values)
+	for i := 0; i < pad; i++ {
+		out[i] = math.NaN()
+	}
+	copy(out[pad:], values)
+	return out
+}
+
+// At возвращает значения на баре i так же, как их вернул бы CalcStochRSI по closes[:i+1]:
+// до прогрева RSI — нули, непрогретые %K и %D заменяются предыдущим рядом цепочки.
+func (s Series) At(i int) StochRSIValues {
+	v := s.at(i)
+	prev := s.at(i - 1)
+	v.PrevRSI, v.PrevRawK, v.PrevK, v.PrevD = prev.RSI, prev.RawK, prev.K, prev.D
+	return v
+}
 
-	values := StochRSIValues{
-		RSI: rsiSeries[len(rsiSeries)-1],
+func (s Series) at(i int) StochRSIValues {
+	if i < 0 || i >= len(s.RSI) || math.IsNaN(s.RSI[i]) {
+		return StochRSIValues{}
 	}
-	if len(rawSeries) > 0 {
-		values.RawK = rawSeries[len(rawSeries)-1]
+	v := StochRSIValues{RSI: s.RSI[i], RawK: s.RawK[i], K: s.K[i], D: s.D[i]}
+	if math.IsNaN(v.RawK) {
+		v.RawK = 0
 	}
-	if len(kSeries) > 0 {
-		values.K = kSeries[len(kSeries)-1]
-	} else {
-		values.K = values.RawK
+	if math.IsNaN(v.K) {
+		v.K = v.RawK
 	}
-	if len(dSeries) > 0 {
-		values.D = dSeries[len(dSeries)-1]
-	} else {
-		values.D = values.K
+	if math.IsNaN(v.D) {
+		v.D = v.K
 	}
+	return v
+}
 
-	return values
+// CalcStochRSI считает Stochastic RSI в формате Bybit/TradingView:
+// 1) RSI по Уайлдеру.
+// 2) Raw Stoch RSI = Stoch(RSI, RSI, RSI, stochPeriod).
+// 3) %K = SMA(raw, smoothK), %D = SMA(%K, smoothD).
+// closes — цены закрытия, старые → новые. Классические значения: 14/14/3/3.
+// Возвращает значения на последнем баре и на предыдущем.
+func CalcStochRSI(closes []float64, rsiPeriod, stochPeriod, smoothK, smoothD int) StochRSIValues {
+	return StochRSISeries(closes, rsiPeriod, stochPeriod, smoothK, smoothD).At(len(closes) - 1)
 }
@@ -1,6 +1,9 @@
 package rsi
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestCalcRSIIncreasingSeries(t *testing.T) {
 	closes := []float64{1, 2, 3, 4, 5, 6, 7, 8}
@@ -33,3 +36,36 @@ func TestCalcStochRSIReturnsSmoothedValues(t *testing.T) {
 		t.Fatalf("D out of range: %.4f", values.D)
 	}
 }
+
+func TestStochRSISeriesIsAlignedWithCloses(t *testing.T) {
+	closes := randomWalk(120, 4)
+	s := StochRSISeries(closes, 14, 14, 3, 3)
+	if len(s.RSI) != len(closes) || len(s.RawK) != len(closes) || len(s.K) != len(closes) || len(s.D) != len(closes) {
+		t.Fatalf("series lengths %d/%d/%d/%d, want %d", len(s.RSI), len(s.RawK), len(s.K), len(s.D), len(closes))
+	}
+	// Первые определённые значения: RSI на баре 14, raw — 27, %K — 29, %D — 31.
+	for name, c := range map[string]struct {
+		values []float64
+		first  int
+	}{"RSI": {s.RSI, 14}, "RawK": {s.RawK, 27}, "K": {s.K, 29}, "D": {s.D, 31}} {
+		if !math.IsNaN(c.values[c.first-1]) || math.IsNaN(c.values[c.first]) {
+			t.Fatalf("%s: first defined value at %d, want warm-up NaN before it", name, c.first)
+		}
+	}
+
+	for i := range closes {
+		want := CalcStochRSI(closes[:i+1], 14, 14, 3, 3)
+		if got := s.At(i); got != want {
+			t.Fatalf("bar %d: At() = %+v, CalcStochRSI = %+v", i, got, want)
+		}
+		if i > 0 {
+			prev := CalcStochRSI(closes[:i], 14, 14, 3, 3)
+			if want.PrevRSI != prev.RSI || want.PrevRawK != prev.RawK || want.PrevK != prev.K || want.PrevD != prev.D {
+				t.Fatalf("bar %d: previous values %+v, want those of bar %d %+v", i, want, i-1, prev)
+			}
+		}
+	}
+	if rsi := RSISeries(closes, 14); rsi[len(rsi)-1] != CalcRSI(closes, 14) || !math.IsNaN(rsi[13]) {
+		t.Fatalf("RSISeries() misaligned: %v", rsi[10:16])
+	}
+}
@@ -0,0 +1,208 @@
+package rsi
+
+// Потоковый Stoch RSI принимает цены по одной и пересчитывает только последний бар:
+// RSI — за O(1), Stoch RSI — за O(stochPeriod) на поиск min/max в окне. Значения совпадают
+// с CalcStochRSI по тем же ценам до последнего бита: порядок арифметики тот же.
+//
+// Next добавляет новый бар, Update заменяет цену последнего бара — так обрабатывается
+// незакрытая свеча, которая меняется до закрытия. Для этого калькулятор хранит
+// состояние до последнего бара и пересчитывает от него.
+
+// wilderState — состояние RSI по Уайлдеру после очередной цены.
+type wilderState struct {
+	n       int // сколько цен получено
+	last    float64
+	avgGain float64
+	avgLoss float64
+	value   float64
+	ok      bool // value определено: получено больше period цен
+}
+
+func (w *wilderState) next(close float64, period int) {
+	if period <= 0 {
+		return
+	}
+	w.n++
+	if w.n == 1 {
+		w.last = close
+		return
+	}
+	diff := close - w.last
+	w.last = close
+	if diffs := w.n - 1; diffs <= period {
+		// Прогрев: первые period изменений усредняются простым средним.
+		if diff > 0 {
+			w.avgGain += diff
+		} else {
+			w.avgLoss -= diff
+		}
+		if diffs < period {
+			return
+		}
+		w.avgGain /= float64(period)
+		w.avgLoss /= float64(period)
+	} else {
+		var g, l float64
+		if diff > 0 {
+			g = diff
+		} else {
+			l = -diff
+		}
+		w.avgGain = (w.avgGain*float64(period-1) + g) / float64(period)
+		w.avgLoss = (w.avgLoss*float64(period-1) + l) / float64(period)
+	}
+	if w.avgLoss == 0 {
+		w.value = 100
+	} else {
+		rs := w.avgGain / w.avgLoss
+		w.value = 100 - (100 / (1 + rs))
+	}
+	w.ok = true
+}
+
+// ring — последние значения ряда в кольцевом буфере.
+type ring struct {
+	buf   []float64
+	count int // сколько значений добавлено всего
+}
+
+func (r *ring) push(v float64) {
+	r.buf[r.count%len(r.buf)] = v
+	r.count++
+}
+
+func (r *ring) full() bool {
+	return r.count >= len(r.buf)
+}
+
+func (r *ring) copyFrom(src *ring) {
+	copy(r.buf, src.buf)
+	r.count = src.count
+}
+
+// smaState — скользящее среднее с накопленной суммой, как в sma.
+type smaState struct {
+	window ring
+	sum    float64
+}
+
+func (s *smaState) next(v float64) (float64, bool) {
+	s.sum += v
+	if s.window.full() {
+		s.sum -= s.window.buf[s.window.count%len(s.window.buf)]
+	}
+	s.window.push(v)
+	if !s.window.full() {
+		return 0, false
+	}
+	return s.sum / float64(len(s.window.buf)), true
+}
+
+func (s *smaState) copyFrom(src *smaState) {
+	s.window.copyFrom(&src.window)
+	s.sum = src.sum
+}
+
+// stochState — состояние Stoch RSI после очередной цены.
+type stochState struct {
+	rsi    wilderState
+	rsis   ring // последние stochPeriod значений RSI
+	k, d   smaState
+	values StochRSIValues
+}
+
+func newStochState(stochPeriod, smoothK, smoothD int) stochState {
+	return stochState{
+		rsis: ring{buf: make([]float64, stochPeriod)},
+		k:    smaState{window: ring{buf: make([]float64, smoothK)}},
+		d:    smaState{window: ring{buf: make([]float64, smoothD)}},
+	}
+}
+
+func (s *stochState) copyFrom(src *stochState) {
+	s.rsi = src.rsi
+	s.rsis.copyFrom(&src.rsis)
+	s.k.copyFrom(&src.k)
+	s.d.copyFrom(&src.d)
+	s.values = src.values
+}
+
+func (s *stochState) next(close float64, rsiPeriod int) {
+	s.rsi.next(close, rsiPeriod)
+	if !s.rsi.ok {
+		return
+	}
+	s.rsis.push(s.rsi.value)
+	prev := s.values
+	v := StochRSIValues{RSI: s.rsi.value, PrevRSI: prev.RSI, PrevRawK: prev.RawK, PrevK: prev.K, PrevD: prev.D}
+	// Пока %K или %D не прогрелись, они равны предыдущему ряду цепочки, как в CalcStochRSI.
+	dOK := false
+	if s.rsis.full() {
+		v.RawK = stochLast(&s.rsis)
+		v.K = v.RawK
+		if k, ok := s.k.next(v.RawK); ok {
+			v.K = k
+			v.D, dOK = s.d.next(k)
+		}
+	}
+	if !dOK {
+		v.D = v.K
+	}
+	s.values = v
+}
+
+// stochLast — положение последнего значения окна между его min и max в процентах.
+func stochLast(r *ring) float64 {
+	cur := r.buf[(r.count-1)%len(r.buf)]
+	minV, maxV := r.buf[0], r.buf[0]
+	for _, v := range r.buf {
+		if v < minV {
+			minV = v
+		}
+		if v > maxV {
+			maxV = v
+		}
+	}
+	if maxV == minV {
+		return 0
+	}
+	return (cur - minV) / (maxV - minV) * 100
+}
+
+// StochRSI — потоковый Stoch RSI в формате Bybit/TradingView, см. CalcStochRSI.
+type StochRSI struct {
+	rsiPeriod int
+	prev, cur stochState
+}
+
+// NewStochRSI создаёт потоковый Stoch RSI. Все периоды должны быть положительными.
+func NewStochRSI(rsiPeriod, stochPeriod, smoothK, smoothD int) *StochRSI {
+	return &StochRSI{
+		rsiPeriod: rsiPeriod,
+		prev:      newStochState(stochPeriod, smoothK, smoothD),
+		cur:       newStochState(stochPeriod, smoothK, smoothD),
+	}
+}
+
+// Next добавляет цену закрытия нового бара и возвращает значения на нём.
+func (s *StochRSI) Next(close float64) StochRSIValues {
+	s.prev.copyFrom(&s.cur)
+	s.cur.next(close, s.rsiPeriod)
+	return s.Values()
+}
+
+// Update заменяет цену последнего бара и возвращает пересчитанные значения.
+// Без единого бара работает как Next.
+func (s *StochRSI) Update(close float64) StochRSIValues {
+	if s.cur.rsi.n == 0 {
+		return s.Next(close)
+	}
+	s.cur.copyFrom(&s.prev)
+	s.cur.next(close, s.rsiPeriod)
+	return s.Values()
+}
+
+// Values возвращает значения последнего бара; до прогрева RSI — нули, как CalcStochRSI.
+func (s *StochRSI) Values() StochRSIValues {
+	return s.cur.values
+}
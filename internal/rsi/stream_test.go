@@ -0,0 +1,81 @@
+package rsi
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomWalk(n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	closes := make([]float64, n)
+	price := 100.0
+	for i := range closes {
+		price += rng.NormFloat64()
+		// Участки без движения проверяют ветки avgLoss == 0 и max == min.
+		if i%50 < 20 && i%50 > 3 {
+			price = closes[i-1]
+		}
+		closes[i] = price
+	}
+	return closes
+}
+
+func TestStreamingMatchesBatch(t *testing.T) {
+	closes := randomWalk(400, 1)
+	for _, p := range [][4]int{{14, 14, 3, 3}, {6, 10, 1, 1}, {2, 1, 5, 7}} {
+		s := NewStochRSI(p[0], p[1], p[2], p[3])
+		for i, c := range closes {
+			got := s.Next(c)
+			if want := CalcStochRSI(closes[:i+1], p[0], p[1], p[2], p[3]); got != want {
+				t.Fatalf("params %v bar %d: Next() = %+v, CalcStochRSI = %+v", p, i, got, want)
+			}
+		}
+	}
+}
+
+func TestStreamingUpdateReplacesLastBar(t *testing.T) {
+	closes := randomWalk(200, 2)
+	s := NewStochRSI(14, 14, 3, 3)
+	prefix := make([]float64, 0, len(closes))
+	for i, c := range closes {
+		// Незакрытая свеча: сначала приходит промежуточная цена, потом несколько обновлений.
+		s.Next(c + 3)
+		s.Update(c - 2)
+		got := s.Update(c)
+		prefix = append(prefix, c)
+		if want := CalcStochRSI(prefix, 14, 14, 3, 3); got != want {
+			t.Fatalf("bar %d: after Update() = %+v, CalcStochRSI = %+v", i, got, want)
+		}
+	}
+}
+
+func BenchmarkCalcStochRSI(b *testing.B) {
+	closes := randomWalk(1000, 3)
+	b.ResetTimer()
+	for range b.N {
+		CalcStochRSI(closes, 14, 14, 3, 3)
+	}
+}
+
+// BenchmarkStochRSINext — цена одного нового бара в потоке; сравнимо с одним вызовом
+// CalcStochRSI на окне candle_limit, которое приходится пересчитывать на каждом баре.
+func BenchmarkStochRSINext(b *testing.B) {
+	closes := randomWalk(1000, 3)
+	s := NewStochRSI(14, 14, 3, 3)
+	b.ResetTimer()
+	for i := range b.N {
+		s.Next(closes[i%len(closes)])
+	}
+}
+
+func BenchmarkStochRSIUpdate(b *testing.B) {
+	closes := randomWalk(1000, 3)
+	s := NewStochRSI(14, 14, 3, 3)
+	for _, c := range closes {
+		s.Next(c)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		s.Update(closes[i%len(closes)])
+	}
+}
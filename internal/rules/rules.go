@@ -0,0 +1,251 @@
+// Package rules — правила сигналов из конфига: дерево условий (all/any/not), сравнения
+// и пересечения именованных выходов индикаторов, условие «N баров подряд».
+//
+// Пример правила «RSI ≥ 75 и %K пересекает %D сверху вниз»:
+//
+//	{"all": [
+//	  {"left": "rsi", "op": ">=", "right": 75},
+//	  {"left": "k", "op": "crosses_below", "right": "d"}
+//	]}
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Индикаторы, доступные в правилах.
+const (
+	RSI   = "rsi"
+	RawK  = "raw_k"
+	K     = "k"
+	D     = "d"
+	Close = "close"
+)
+
+// Indicators — допустимые имена выходов индикаторов.
+var Indicators = []string{RSI, RawK, K, D, Close}
+
+// Source отдаёт значение индикатора barsAgo баров назад (0 — последний бар).
+// ok=false, если для этого бара значения нет (не хватило истории).
+type Source interface {
+	Value(name string, barsAgo int) (value float64, ok bool)
+}
+
+// Operand — правая часть сравнения: число или имя индикатора.
+type Operand struct {
+	Name  string
+	Value float64
+}
+
+// UnmarshalJSON принимает число или строку с именем индикатора.
+func (o *Operand) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*o = Operand{Name: name}
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("операнд должен быть числом или именем индикатора: %s", data)
+	}
+	*o = Operand{Value: value}
+	return nil
+}
+
+// MarshalJSON сохраняет операнд в исходном виде.
+func (o Operand) MarshalJSON() ([]byte, error) {
+	if o.Name != "" {
+		return json.Marshal(o.Name)
+	}
+	return json.Marshal(o.Value)
+}
+
+func (o Operand) value(src Source, barsAgo int) (float64, bool) {
+	if o.Name == "" {
+		return o.Value, true
+	}
+	return src.Value(o.Name, barsAgo)
+}
+
+// Rule — узел дерева условий. Заполняется ровно одно из: All, Any, Not или сравнение Left/Op/Right.
+type Rule struct {
+	All   []*Rule  `json:"all,omitempty"`
+	Any   []*Rule  `json:"any,omitempty"`
+	Not   *Rule    `json:"not,omitempty"`
+	Left  string   `json:"left,omitempty"`  // имя индикатора
+	Op    string   `json:"op,omitempty"`    // >, >=, <, <=, ==, !=, crosses_above, crosses_below
+	Right *Operand `json:"right,omitempty"` // число или имя индикатора
+	// ForBars — условие узла должно выполняться на каждом из последних N баров.
+	ForBars int `json:"for_bars,omitempty"`
+}
+
+var ops = map[string]bool{
+	">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true,
+	"crosses_above": true, "crosses_below": true,
+}
+
+// Validate проверяет структуру правила и имена индикаторов.
+func (r *Rule) Validate() error {
+	if r == nil {
+		return errors.New("пустое правило")
+	}
+	if r.ForBars < 0 {
+		return fmt.Errorf("for_bars не может быть отрицательным: %d", r.ForBars)
+	}
+	kinds := 0
+	if len(r.All) > 0 {
+		kinds++
+	}
+	if len(r.Any) > 0 {
+		kinds++
+	}
+	if r.Not != nil {
+		kinds++
+	}
+	if r.Op != "" || r.Left != "" || r.Right != nil {
+		kinds++
+	}
+	if kinds != 1 {
+		return errors.New("узел правила должен содержать ровно одно из all, any, not или сравнение left/op/right")
+	}
+
+	for _, children := range [][]*Rule{r.All, r.Any} {
+		for _, child := range children {
+			if err := child.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	if r.Not != nil {
+		return r.Not.Validate()
+	}
+	if len(r.All) > 0 || len(r.Any) > 0 {
+		return nil
+	}
+
+	if !ops[r.Op] {
+		return fmt.Errorf("неизвестный оператор %q", r.Op)
+	}
+	if !knownIndicator(r.Left) {
+		return fmt.Errorf("неизвестный индикатор %q (доступны: %s)", r.Left, strings.Join(Indicators, ", "))
+	}
+	if r.Right == nil {
+		return fmt.Errorf("у сравнения %s %s нет правой части", r.Left, r.Op)
+	}
+	if r.Right.Name != "" && !knownIndicator(r.Right.Name) {
+		return fmt.Errorf("неизвестный индикатор %q (доступны: %s)", r.Right.Name, strings.Join(Indicators, ", "))
+	}
+	return nil
+}
+
+// Lookback возвращает, сколько баров истории нужно правилу сверх последнего.
+func (r *Rule) Lookback() int {
+	own := 0
+	if r.Op == "crosses_above" || r.Op == "crosses_below" {
+		own = 1
+	}
+	for _, children := range [][]*Rule{r.All, r.Any} {
+		for _, child := range children {
+			if l := child.Lookback(); l > own {
+				own = l
+			}
+		}
+	}
+	if r.Not != nil {
+		if l := r.Not.Lookback(); l > own {
+			own = l
+		}
+	}
+	if r.ForBars > 1 {
+		own += r.ForBars - 1
+	}
+	return own
+}
+
+// Eval вычисляет правило на последнем баре. Нехватка истории означает «не выполнено».
+func (r *Rule) Eval(src Source) bool {
+	return r.evalAt(src, 0)
+}
+
+func (r *Rule) evalAt(src Source, barsAgo int) bool {
+	n := r.ForBars
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		if !r.evalOnce(src, barsAgo+i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) evalOnce(src Source, barsAgo int) bool {
+	switch {
+	case len(r.All) > 0:
+		for _, child := range r.All {
+			if !child.evalAt(src, barsAgo) {
+				return false
+			}
+		}
+		return true
+	case len(r.Any) > 0:
+		for _, child := range r.Any {
+			if child.evalAt(src, barsAgo) {
+				return true
+			}
+		}
+		return false
+	case r.Not != nil:
+		return !r.Not.evalAt(src, barsAgo)
+	}
+
+	left, ok := src.Value(r.Left, barsAgo)
+	if !ok || r.Right == nil {
+		return false
+	}
+	right, ok := r.Right.value(src, barsAgo)
+	if !ok {
+		return false
+	}
+	switch r.Op {
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "crosses_above", "crosses_below":
+		prevLeft, ok := src.Value(r.Left, barsAgo+1)
+		if !ok {
+			return false
+		}
+		prevRight, ok := r.Right.value(src, barsAgo+1)
+		if !ok {
+			return false
+		}
+		if r.Op == "crosses_above" {
+			return prevLeft <= prevRight && left > right
+		}
+		return prevLeft >= prevRight && left < right
+	}
+	return false
+}
+
+func knownIndicator(name string) bool {
+	for _, ind := range Indicators {
+		if ind == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// series — источник значений для тестов: последние элементы срезов соответствуют текущему бару.
+type series map[string][]float64
+
+func (s series) Value(name string, barsAgo int) (float64, bool) {
+	values := s[name]
+	i := len(values) - 1 - barsAgo
+	if i < 0 {
+		return 0, false
+	}
+	return values[i], true
+}
+
+func parse(t *testing.T, text string) *Rule {
+	t.Helper()
+	var r Rule
+	if err := json.Unmarshal([]byte(text), &r); err != nil {
+		t.Fatalf("unmarshal %s: %v", text, err)
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate(%s): %v", text, err)
+	}
+	return &r
+}
+
+func TestRuleRSIAndKCrossesBelowD(t *testing.T) {
+	r := parse(t, `{"all": [
+		{"left": "rsi", "op": ">=", "right": 75},
+		{"left": "k", "op": "crosses_below", "right": "d"}
+	]}`)
+
+	src := series{"rsi": {70, 80, 78}, "k": {90, 95, 85}, "d": {80, 90, 88}}
+	if !r.Eval(src) {
+		t.Fatal("rule should fire when RSI >= 75 and K crosses below D")
+	}
+	src["k"] = []float64{90, 85, 80}
+	if r.Eval(src) {
+		t.Fatal("rule should not fire when K was already below D")
+	}
+	if r.Lookback() != 1 {
+		t.Fatalf("Lookback() = %d, want 1", r.Lookback())
+	}
+}
+
+func TestRuleAnyNotAndForBars(t *testing.T) {
+	r := parse(t, `{"any": [
+		{"left": "rsi", "op": "<=", "right": 30, "for_bars": 3},
+		{"not": {"left": "k", "op": ">", "right": 0}}
+	]}`)
+
+	if !r.Eval(series{"rsi": {25, 28, 29}, "k": {10, 10, 10}}) {
+		t.Fatal("RSI <= 30 for 3 bars should fire")
+	}
+	if r.Eval(series{"rsi": {35, 28, 29}, "k": {10, 10, 10}}) {
+		t.Fatal("RSI <= 30 for only 2 bars should not fire")
+	}
+	if !r.Eval(series{"rsi": {50}, "k": {0}}) {
+		t.Fatal("NOT K > 0 should fire at K = 0")
+	}
+	if r.Eval(series{"rsi": {28, 29}, "k": {5, 5}}) {
+		t.Fatal("missing history should not fire")
+	}
+}
+
+func TestRuleValidateRejectsMalformed(t *testing.T) {
+	for _, text := range []string{
+		`{}`,
+		`{"left": "macd", "op": ">", "right": 1}`,
+		`{"left": "rsi", "op": "~", "right": 1}`,
+		`{"left": "rsi", "op": ">"}`,
+		`{"left": "rsi", "op": ">", "right": 1, "all": [{"left": "k", "op": ">", "right": 1}]}`,
+		`{"all": [{"left": "k", "op": ">", "right": "volume"}]}`,
+	} {
+		var r Rule
+		if err := json.Unmarshal([]byte(text), &r); err != nil {
+			continue
+		}
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%s) accepted malformed rule", text)
+		}
+	}
+}
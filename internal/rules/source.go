@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+// IndicatorSource отдаёт правилам значения RSI/Stoch RSI на прошлых барах. Последний
+// и предыдущий бар берутся из уже посчитанных значений, более ранние — из полного ряда,
+// который считается один раз при первом обращении.
+type IndicatorSource struct {
+	closes                                   []float64
+	rsiPeriod, stochPeriod, smoothK, smoothD int
+	last                                     rsi.StochRSIValues
+	series                                   *rsi.Series
+}
+
+// NewIndicatorSource создаёт источник по ценам закрытия; last — уже посчитанные значения последнего бара.
+func NewIndicatorSource(closes []float64, rsiPeriod, stochPeriod, smoothK, smoothD int, last rsi.StochRSIValues) *IndicatorSource {
+	return &IndicatorSource{
+		closes:      closes,
+		rsiPeriod:   rsiPeriod,
+		stochPeriod: stochPeriod,
+		smoothK:     smoothK,
+		smoothD:     smoothD,
+		last:        last,
+	}
+}
+
+// Value реализует Source.
+func (s *IndicatorSource) Value(name string, barsAgo int) (float64, bool) {
+	n := len(s.closes) - barsAgo
+	if barsAgo < 0 || n <= 0 {
+		return 0, false
+	}
+	if name == Close {
+		return s.closes[n-1], true
+	}
+	// Для %D нужно stochPeriod+smoothK+smoothD-2 значений RSI, иначе значения на баре неполные.
+	if n < s.rsiPeriod+s.stochPeriod+s.smoothK+s.smoothD-2 {
+		return 0, false
+	}
+	var values rsi.StochRSIValues
+	switch barsAgo {
+	case 0:
+		values = s.last
+	case 1:
+		values = rsi.StochRSIValues{RSI: s.last.PrevRSI, RawK: s.last.PrevRawK, K: s.last.PrevK, D: s.last.PrevD}
+	default:
+		if s.series == nil {
+			series := rsi.StochRSISeries(s.closes, s.rsiPeriod, s.stochPeriod, s.smoothK, s.smoothD)
+			s.series = &series
+		}
+		values = s.series.At(n - 1)
+	}
+	switch name {
+	case RSI:
+		return values.RSI, true
+	case RawK:
+		return values.RawK, true
+	case K:
+		return values.K, true
+	case D:
+		return values.D, true
+	}
+	return 0, false
+}
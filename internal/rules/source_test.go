@@ -0,0 +1,28 @@
+package rules
+
+import (
+	"math"
+	"testing"
+
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+func TestIndicatorSourceMatchesRecomputedPrefix(t *testing.T) {
+	closes := make([]float64, 80)
+	for i := range closes {
+		closes[i] = 100 + 10*math.Sin(float64(i)/5) + float64(i%7)
+	}
+	last := rsi.CalcStochRSI(closes, 14, 14, 3, 3)
+	src := NewIndicatorSource(closes, 14, 14, 3, 3, last)
+
+	for barsAgo := 0; barsAgo < 40; barsAgo++ {
+		n := len(closes) - barsAgo
+		want := rsi.CalcStochRSI(closes[:n], 14, 14, 3, 3)
+		for name, w := range map[string]float64{RSI: want.RSI, RawK: want.RawK, K: want.K, D: want.D} {
+			got, ok := src.Value(name, barsAgo)
+			if defined := n >= 14+14+3+3-2; ok != defined || (ok && got != w) {
+				t.Fatalf("Value(%s, %d) = %v, %v; want %v, %v", name, barsAgo, got, ok, w, defined)
+			}
+		}
+	}
+}
@@ -0,0 +1,523 @@
+// Package scanner — общий сканер рынка: один раз за цикл получает список пар и свечи
+// по каждой тройке (биржа, символ, таймфрейм) и раздаёт их всем ботам, которым они нужны.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/candlecache"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/universe"
+)
+
+// DefaultWorkers — число одновременных запросов свечей к бирже по умолчанию.
+const DefaultWorkers = 8
+
+// streamBacklog — сколько обновлений WebSocket может ждать обработки.
+const streamBacklog = 1024
+
+// Consumer — получатель свечей (обычно один Telegram-бот).
+type Consumer interface {
+	Name() string
+	Exchange() string
+	// DataSource возвращает poll (опрос REST) или stream (WebSocket).
+	DataSource() string
+	Timeframes() []string
+	CandleLimit() int
+	// MaxSignalsPerCycle — лимит уведомлений за цикл опроса, а в потоке — за свечу таймфрейма.
+	MaxSignalsPerCycle() int
+	// Universe возвращает фильтр пар, которые нужны получателю.
+	Universe() universe.Filter
+	// ProcessCandles обрабатывает свечи (старые → новые) и возвращает true, если ушло уведомление.
+	// send=false — лимит уведомлений исчерпан: значения только обновляются для /top.
+	ProcessCandles(symbol, timeframe string, candles []exchange.Candle, send bool) bool
+}
+
+// Scanner раз в Interval проходит по рынку и кормит всех получателей.
+type Scanner struct {
+	consumers []Consumer
+	exchanges map[string]exchange.Exchange
+	// caches — свечи каждой биржи между циклами: после первой загрузки догружаются только новые.
+	caches map[string]*candlecache.Cache
+	// Interval — пауза между циклами анализа.
+	Interval time.Duration
+	// Workers — сколько запросов свечей к одной бирже выполняется одновременно.
+	Workers int
+	// RequestDelay — минимальный интервал между запросами свечей к биржам без учёта
+	// лимитов по ответам (Binance, OKX); Bybit притормаживает сам.
+	RequestDelay time.Duration
+
+	mu sync.Mutex
+	// allowed — пары, прошедшие фильтр каждого получателя при последнем обновлении списка пар.
+	allowed map[Consumer]map[string]bool
+}
+
+// New создаёт сканер для списка получателей. available — адаптеры бирж по имени;
+// сканер берёт те же экземпляры, что и боты, чтобы лимиты запросов к бирже считались вместе.
+func New(consumers []Consumer, available map[string]exchange.Exchange) (*Scanner, error) {
+	exchanges := make(map[string]exchange.Exchange)
+	caches := make(map[string]*candlecache.Cache)
+	for _, c := range consumers {
+		if _, ok := exchanges[c.Exchange()]; ok {
+			continue
+		}
+		ex, ok := available[c.Exchange()]
+		if !ok {
+			return nil, fmt.Errorf("%s: нет адаптера биржи %q", c.Name(), c.Exchange())
+		}
+		exchanges[c.Exchange()] = ex
+		caches[c.Exchange()] = candlecache.New(ex)
+	}
+	return &Scanner{
+		consumers:    consumers,
+		exchanges:    exchanges,
+		caches:       caches,
+		Interval:     time.Minute,
+		Workers:      DefaultWorkers,
+		RequestDelay: 50 * time.Millisecond,
+		allowed:      make(map[Consumer]map[string]bool),
+	}, nil
+}
+
+// LoadCandleCache сохраняет кэш свечей каждой биржи в каталоге dir
+// (candles.<биржа>.json) и загружает то, что сохранилось с прошлого запуска.
+func (s *Scanner) LoadCandleCache(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, cache := range s.caches {
+		if err := cache.Load(filepath.Join(dir, "candles."+name+".json")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run крутит циклы анализа до отмены ctx. Боты с data_source=stream получают свечи
+// из WebSocket, а раз в Interval сканер лишь обновляет список их подписок.
+func (s *Scanner) Run(ctx context.Context) {
+	streams := s.startStreams(ctx)
+	defer s.saveCaches()
+	for {
+		s.cycle(ctx)
+		for name, st := range streams {
+			s.refreshTopics(s.exchanges[name], st)
+		}
+		now := time.Now()
+		for name, cache := range s.caches {
+			cache.Prune(now)
+			if err := cache.Persist(); err != nil {
+				log.Printf("[%s] Не удалось сохранить кэш свечей: %v", name, err)
+			}
+		}
+		log.Printf("Анализ завершён. Следующий запуск через %s...", s.Interval)
+		if !sleep(ctx, s.Interval) {
+			return
+		}
+	}
+}
+
+func (s *Scanner) saveCaches() {
+	for name, cache := range s.caches {
+		if err := cache.Save(); err != nil {
+			log.Printf("[%s] Не удалось сохранить кэш свечей: %v", name, err)
+		}
+	}
+}
+
+// cycle выполняет один проход опроса по всем биржам.
+func (s *Scanner) cycle(ctx context.Context) {
+	for name, ex := range s.exchanges {
+		if ctx.Err() != nil {
+			return
+		}
+		if consumers := s.consumersOf(name, "poll"); len(consumers) > 0 {
+			s.scanExchange(ctx, ex, consumers)
+		}
+	}
+}
+
+func (s *Scanner) consumersOf(exchangeName, source string) []Consumer {
+	var out []Consumer
+	for _, c := range s.consumers {
+		if c.Exchange() == exchangeName && c.DataSource() == source {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// startStreams запускает WebSocket-потоки для бирж, у которых есть боты с data_source=stream.
+func (s *Scanner) startStreams(ctx context.Context) map[string]*exchange.BybitStream {
+	streams := make(map[string]*exchange.BybitStream)
+	for name, ex := range s.exchanges {
+		consumers := s.consumersOf(name, "stream")
+		rest, ok := ex.(*exchange.BybitExchange)
+		if len(consumers) == 0 || !ok {
+			continue
+		}
+		limit := 0
+		for _, c := range consumers {
+			if l := c.CandleLimit(); l > limit {
+				limit = l
+			}
+		}
+		st := exchange.NewBybitStream(rest, limit)
+		s.refreshTopics(ex, st)
+		// Чтение WebSocket не ждёт обработки: отправка сигнала может стоять в очереди Telegram
+		// дольше таймаута чтения, и соединение рвалось бы.
+		updates := make(chan exchange.KlineUpdate, streamBacklog)
+		go s.processStream(ctx, consumers, updates)
+		go st.Run(ctx, func(upd exchange.KlineUpdate) { queueUpdate(name, updates, upd) })
+		streams[name] = st
+	}
+	return streams
+}
+
+// queueUpdate ставит обновление потока в очередь без ожидания. Каждое обновление несёт
+// весь буфер свечей, поэтому пропущенное при переполнении восстановит следующее по этой паре.
+func queueUpdate(name string, updates chan<- exchange.KlineUpdate, upd exchange.KlineUpdate) {
+	select {
+	case updates <- upd:
+	default:
+		log.Printf("[%s] Очередь обновлений потока переполнена, %s %s пропущено", name, upd.Symbol, upd.Timeframe)
+	}
+}
+
+// processStream раздаёт обновления потока получателям до отмены ctx. Циклов опроса
+// в потоке нет, поэтому лимит уведомлений действует на свечу таймфрейма: счётчик
+// сбрасывается, когда по таймфрейму приходит первое обновление новой свечи.
+func (s *Scanner) processStream(ctx context.Context, consumers []Consumer, updates <-chan exchange.KlineUpdate) {
+	windows := make(map[string]*streamWindow)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case upd := <-updates:
+			notes := windowBudget(windows, upd)
+			for _, c := range consumers {
+				if hasTimeframe(c, upd.Timeframe) && s.allows(c, upd.Symbol) {
+					notes.process(c, upd.Symbol, upd.Timeframe, tail(upd.Candles, c.CandleLimit()))
+				}
+			}
+		}
+	}
+}
+
+// streamWindow — лимит уведомлений потока на одну свечу таймфрейма.
+type streamWindow struct {
+	bar   time.Time // время открытия последней свечи таймфрейма
+	notes *budget
+}
+
+// windowBudget возвращает лимит уведомлений для свечи обновления и открывает новое окно,
+// если свеча новее всех, что уже приходили по этому таймфрейму.
+func windowBudget(windows map[string]*streamWindow, upd exchange.KlineUpdate) *budget {
+	w := windows[upd.Timeframe]
+	var bar time.Time
+	if len(upd.Candles) > 0 {
+		bar = upd.Candles[len(upd.Candles)-1].OpenTime
+	}
+	if w == nil || bar.After(w.bar) {
+		w = &streamWindow{bar: bar, notes: newBudget()}
+		windows[upd.Timeframe] = w
+	}
+	return w.notes
+}
+
+// refreshTopics подписывает поток на пары биржи, которые проходят фильтр хотя бы одного
+// из его ботов, по таймфреймам этих ботов.
+func (s *Scanner) refreshTopics(ex exchange.Exchange, st *exchange.BybitStream) {
+	consumers := s.consumersOf(ex.Name(), "stream")
+	timeframes := make(map[string]bool)
+	for _, c := range consumers {
+		for _, tf := range c.Timeframes() {
+			timeframes[tf] = true
+		}
+	}
+	symbols, err := s.loadUniverse(ex, consumers)
+	if err != nil {
+		log.Printf("[%s] Ошибка получения пар для потока: %v", ex.Name(), err)
+		return
+	}
+	var keys []exchange.StreamKey
+	for tf := range timeframes {
+		for _, symbol := range symbols {
+			keys = append(keys, exchange.StreamKey{Symbol: symbol, Timeframe: tf})
+		}
+	}
+	st.SetTopics(keys)
+}
+
+// loadUniverse получает пары биржи, применяет к ним фильтры получателей и запоминает результат.
+// Возвращает пары, которые нужны хотя бы одному получателю, в порядке биржи.
+func (s *Scanner) loadUniverse(ex exchange.Exchange, consumers []Consumer) ([]string, error) {
+	pairs, err := fetchPairs(ex, consumers)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	allowed := make(map[Consumer]map[string]bool, len(consumers))
+	needed := make(map[string]bool, len(pairs))
+	for _, c := range consumers {
+		filter := c.Universe()
+		set := make(map[string]bool, len(pairs))
+		for _, p := range pairs {
+			if filter.Allows(p, now) {
+				set[p.Symbol] = true
+				needed[p.Symbol] = true
+			}
+		}
+		allowed[c] = set
+		if len(set) < len(pairs) {
+			log.Printf("[%s] Фильтры пар: сканируется %d из %d", c.Name(), len(set), len(pairs))
+		}
+	}
+
+	s.mu.Lock()
+	for c, set := range allowed {
+		s.allowed[c] = set
+	}
+	s.mu.Unlock()
+
+	var symbols []string
+	for _, p := range pairs {
+		if needed[p.Symbol] {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	return symbols, nil
+}
+
+// fetchPairs получает пары биржи. Метаданные контрактов запрашиваются, если биржа их отдаёт,
+// суточный оборот — только если он нужен чьему-то фильтру.
+func fetchPairs(ex exchange.Exchange, consumers []Consumer) ([]universe.Pair, error) {
+	info, ok := ex.(exchange.MarketInfo)
+	if !ok {
+		symbols, err := ex.DerivativePairs()
+		if err != nil {
+			return nil, err
+		}
+		pairs := make([]universe.Pair, len(symbols))
+		for i, symbol := range symbols {
+			pairs[i] = universe.Pair{Symbol: symbol}
+		}
+		return pairs, nil
+	}
+
+	instruments, err := info.Instruments()
+	if err != nil {
+		return nil, err
+	}
+	var turnover map[string]float64
+	for _, c := range consumers {
+		if c.Universe().NeedsTurnover() {
+			if turnover, err = info.Turnover24h(); err != nil {
+				return nil, fmt.Errorf("оборот за сутки: %w", err)
+			}
+			break
+		}
+	}
+	pairs := make([]universe.Pair, 0, len(instruments))
+	for _, in := range instruments {
+		if in.Trading() {
+			pairs = append(pairs, universe.Pair{Symbol: in.Symbol, QuoteCoin: in.QuoteCoin, LaunchTime: in.LaunchTime, Turnover24h: turnover[in.Symbol]})
+		}
+	}
+	return pairs, nil
+}
+
+// allows сообщает, прошёл ли символ фильтр получателя при последнем обновлении списка пар.
+func (s *Scanner) allows(c Consumer, symbol string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allowed[c][symbol]
+}
+
+// scanExchange проходит по парам одной биржи: каждая пара (символ, таймфрейм) запрашивается
+// ровно один раз с наибольшим candle_limit среди ботов, которым нужен этот таймфрейм.
+func (s *Scanner) scanExchange(ctx context.Context, ex exchange.Exchange, consumers []Consumer) {
+	limits := make(map[string]int)
+	var timeframes []string
+	for _, c := range consumers {
+		for _, tf := range c.Timeframes() {
+			if _, ok := limits[tf]; !ok {
+				timeframes = append(timeframes, tf)
+			}
+			if l := c.CandleLimit(); l > limits[tf] {
+				limits[tf] = l
+			}
+		}
+	}
+	if len(timeframes) == 0 {
+		log.Printf("[%s] Нет подписчиков ни у одного бота, анализ пропущен", ex.Name())
+		return
+	}
+	log.Printf("[%s] Запуск анализа рынка (ботов %d, таймфреймы %v)...", ex.Name(), len(consumers), timeframes)
+
+	symbols, err := s.loadUniverse(ex, consumers)
+	if err != nil {
+		log.Printf("[%s] Ошибка получения пар: %v", ex.Name(), err)
+		return
+	}
+
+	start := time.Now()
+	before := s.caches[ex.Name()].Stats()
+	pace := s.pacer(ex)
+	// Лимит уведомлений один на весь цикл, сколько бы таймфреймов в нём ни было.
+	notes := newBudget()
+	for _, tf := range timeframes {
+		if ctx.Err() != nil {
+			return
+		}
+		s.scanTimeframe(ctx, ex, consumers, tf, limits[tf], symbols, pace, notes)
+	}
+	after := s.caches[ex.Name()].Stats()
+	log.Printf("[%s] Пары просканированы за %s: загрузок целиком %d, догрузок %d, разрывов %d",
+		ex.Name(), time.Since(start).Round(time.Millisecond),
+		after.Full-before.Full, after.Incremental-before.Incremental, after.Gaps-before.Gaps)
+}
+
+// scanTimeframe запрашивает свечи таймфрейма по всем парам в Workers потоков.
+// Все пары обрабатываются каждым получателем, чтобы /top видел весь рынок; лимит
+// уведомлений notes ограничивает только рассылку.
+func (s *Scanner) scanTimeframe(ctx context.Context, ex exchange.Exchange, consumers []Consumer, tf string, limit int, symbols []string, pace *pacer, notes *budget) {
+	cache := s.caches[ex.Name()]
+	consumers = slices.DeleteFunc(slices.Clone(consumers), func(c Consumer) bool { return !hasTimeframe(c, tf) })
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range max(s.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range jobs {
+				active := slices.DeleteFunc(slices.Clone(consumers), func(c Consumer) bool { return !s.allows(c, symbol) })
+				if len(active) == 0 || !pace.wait(ctx) {
+					continue
+				}
+				candles, err := cache.Candles(symbol, tf, limit)
+				if err != nil {
+					log.Printf("[%s] Ошибка свечей %s: %v", ex.Name(), symbol, err)
+					continue
+				}
+				for _, c := range active {
+					notes.process(c, symbol, tf, tail(candles, c.CandleLimit()))
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, symbol := range symbols {
+		select {
+		case jobs <- symbol:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// budget считает уведомления каждого получателя в пределах его MaxSignalsPerCycle.
+type budget struct {
+	mu   sync.Mutex
+	sent map[Consumer]int
+}
+
+func newBudget() *budget {
+	return &budget{sent: make(map[Consumer]int)}
+}
+
+// process передаёт свечи получателю. Место под уведомление занимается до обработки,
+// поэтому параллельные потоки не могут вместе превысить лимит; если уведомление
+// не ушло, место освобождается.
+func (b *budget) process(c Consumer, symbol, timeframe string, candles []exchange.Candle) {
+	send := b.reserve(c)
+	if !c.ProcessCandles(symbol, timeframe, candles, send) && send {
+		b.release(c)
+	}
+}
+
+func (b *budget) reserve(c Consumer) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sent[c] >= c.MaxSignalsPerCycle() {
+		return false
+	}
+	b.sent[c]++
+	return true
+}
+
+func (b *budget) release(c Consumer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent[c]--
+}
+
+// pacer выдерживает интервал между началами запросов всех потоков к одной бирже.
+type pacer struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// pacer возвращает темп запросов к бирже. Bybit сам следит за своими лимитами
+// по заголовкам ответов, остальным биржам запросы отдаются не чаще RequestDelay.
+func (s *Scanner) pacer(ex exchange.Exchange) *pacer {
+	if _, ok := ex.(*exchange.BybitExchange); ok {
+		return &pacer{}
+	}
+	return &pacer{interval: s.RequestDelay}
+}
+
+// wait ждёт своей очереди на запрос; false — ctx отменён.
+func (p *pacer) wait(ctx context.Context) bool {
+	if p.interval <= 0 {
+		return ctx.Err() == nil
+	}
+	p.mu.Lock()
+	now := time.Now()
+	at := now
+	if p.next.After(at) {
+		at = p.next
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+	return sleep(ctx, at.Sub(now))
+}
+
+func hasTimeframe(c Consumer, timeframe string) bool {
+	for _, tf := range c.Timeframes() {
+		if tf == timeframe {
+			return true
+		}
+	}
+	return false
+}
+
+func tail(candles []exchange.Candle, n int) []exchange.Candle {
+	if n <= 0 || len(candles) <= n {
+		return candles
+	}
+	return candles[len(candles)-n:]
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
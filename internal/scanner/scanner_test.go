@@ -0,0 +1,239 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grevtsevalex/crypto-bot/internal/candlecache"
+	"grevtsevalex/crypto-bot/internal/exchange"
+	"grevtsevalex/crypto-bot/internal/universe"
+)
+
+// fakeExchange отдаёт одну свечу на пару и считает одновременные запросы.
+type fakeExchange struct {
+	symbols        []string
+	inflight, peak int32
+	requests       int32
+}
+
+func (f *fakeExchange) Name() string                       { return "fake" }
+func (f *fakeExchange) DerivativePairs() ([]string, error) { return f.symbols, nil }
+func (f *fakeExchange) ServerTime() (time.Time, error)     { return time.Now(), nil }
+
+func (f *fakeExchange) Candles(symbol, timeframe string, limit int) ([]exchange.Candle, error) {
+	atomic.AddInt32(&f.requests, 1)
+	n := atomic.AddInt32(&f.inflight, 1)
+	defer atomic.AddInt32(&f.inflight, -1)
+	for {
+		peak := atomic.LoadInt32(&f.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&f.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return []exchange.Candle{{Close: 1, Confirmed: true}}, nil
+}
+
+type fakeConsumer struct {
+	filter     universe.Filter
+	maxSignal  int
+	timeframes []string // по умолчанию только 60
+	signal     func(symbol string) bool
+
+	mu        sync.Mutex
+	processed map[string]bool
+	calls     int
+	notified  int
+}
+
+func (c *fakeConsumer) Name() string       { return "fake" }
+func (c *fakeConsumer) Exchange() string   { return "fake" }
+func (c *fakeConsumer) DataSource() string { return "poll" }
+
+func (c *fakeConsumer) Timeframes() []string {
+	if c.timeframes == nil {
+		return []string{"60"}
+	}
+	return c.timeframes
+}
+
+func (c *fakeConsumer) CandleLimit() int          { return 1 }
+func (c *fakeConsumer) MaxSignalsPerCycle() int   { return c.maxSignal }
+func (c *fakeConsumer) Universe() universe.Filter { return c.filter }
+
+func (c *fakeConsumer) ProcessCandles(symbol, timeframe string, candles []exchange.Candle, send bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.processed[symbol] = true
+	c.calls++
+	if !send || c.signal == nil || !c.signal(symbol) {
+		return false
+	}
+	c.notified++
+	return true
+}
+
+func newTestScanner(ex exchange.Exchange, consumers ...Consumer) *Scanner {
+	return &Scanner{
+		consumers: consumers,
+		exchanges: map[string]exchange.Exchange{"fake": ex},
+		caches:    map[string]*candlecache.Cache{"fake": candlecache.New(ex)},
+		Workers:   4,
+		allowed:   make(map[Consumer]map[string]bool),
+	}
+}
+
+func TestScanExchangeRunsWorkersInParallel(t *testing.T) {
+	ex := &fakeExchange{symbols: []string{"A", "B", "C", "D", "E", "F", "G", "H", "SKIP"}}
+	c := &fakeConsumer{filter: universe.Filter{Exclude: []string{"SKIP"}}, maxSignal: 100, processed: map[string]bool{}}
+	s := newTestScanner(ex, c)
+
+	start := time.Now()
+	s.scanExchange(context.Background(), ex, []Consumer{c})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("8 requests of 20ms with 4 workers took %v", elapsed)
+	}
+	if ex.peak < 2 || ex.peak > 4 {
+		t.Fatalf("peak concurrency = %d, want 2..4", ex.peak)
+	}
+	if len(c.processed) != 8 || c.processed["SKIP"] || ex.requests != 8 {
+		t.Fatalf("processed %v with %d requests, want 8 symbols without SKIP", c.processed, ex.requests)
+	}
+}
+
+func TestScanExchangeLimitsOnlyNotifications(t *testing.T) {
+	symbols := make([]string, 100)
+	for i := range symbols {
+		symbols[i] = string(rune('A'+i/26)) + string(rune('a'+i%26))
+	}
+	ex := &fakeExchange{symbols: symbols}
+	c := &fakeConsumer{maxSignal: 2, processed: map[string]bool{}, signal: func(string) bool { return true }}
+	s := newTestScanner(ex, c)
+	s.Workers = 2
+
+	s.scanExchange(context.Background(), ex, []Consumer{c})
+	// Каждая пара даёт сигнал: после двух сигналов уведомления прекращаются,
+	// но все пары по-прежнему считаются для /top.
+	if len(c.processed) != len(symbols) || ex.requests != int32(len(symbols)) {
+		t.Fatalf("processed %d pairs with %d requests, want all %d", len(c.processed), ex.requests, len(symbols))
+	}
+	if c.notified != 2 {
+		t.Fatalf("notified = %d with the signal limit of 2 and %d workers", c.notified, s.Workers)
+	}
+}
+
+func TestScanExchangeLimitsNotificationsAcrossTimeframes(t *testing.T) {
+	ex := &fakeExchange{symbols: []string{"A", "B", "C", "D"}}
+	c := &fakeConsumer{maxSignal: 3, timeframes: []string{"15", "60", "240"}, processed: map[string]bool{}, signal: func(string) bool { return true }}
+	s := newTestScanner(ex, c)
+
+	s.scanExchange(context.Background(), ex, []Consumer{c})
+	// Лимит один на цикл: три таймфрейма по четыре сигнала дают всего три уведомления.
+	if c.notified != 3 {
+		t.Fatalf("notified = %d over 3 timeframes, want the cycle limit of 3", c.notified)
+	}
+}
+
+func TestNewSharesExchanges(t *testing.T) {
+	ex := &fakeExchange{symbols: []string{"A"}}
+	c := &fakeConsumer{maxSignal: 1, processed: map[string]bool{}}
+	s, err := New([]Consumer{c}, map[string]exchange.Exchange{"fake": ex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.exchanges["fake"] != ex || s.caches["fake"] == nil {
+		t.Fatalf("New() exchanges = %v, caches = %v, want the given adapter with its cache", s.exchanges, s.caches)
+	}
+	s.cycle(context.Background())
+	if !c.processed["A"] {
+		t.Fatal("cycle() did not scan through the shared adapter")
+	}
+	if _, err := New([]Consumer{c}, nil); err == nil {
+		t.Fatal("New() accepted a consumer without an exchange adapter")
+	}
+}
+
+// blockingConsumer обрабатывает свечи, только когда тест разрешит.
+type blockingConsumer struct {
+	fakeConsumer
+	release chan struct{}
+	got     chan string
+}
+
+func (c *blockingConsumer) ProcessCandles(symbol, timeframe string, candles []exchange.Candle, send bool) bool {
+	<-c.release
+	c.got <- symbol
+	return false
+}
+
+func TestStreamUpdatesDoNotWaitForProcessing(t *testing.T) {
+	c := &blockingConsumer{release: make(chan struct{}), got: make(chan string, 2)}
+	s := newTestScanner(&fakeExchange{})
+	s.allowed[c] = map[string]bool{"A": true, "B": true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan exchange.KlineUpdate, 2)
+	go s.processStream(ctx, []Consumer{c}, updates)
+	done := make(chan struct{})
+	go func() {
+		// Обработчик занят первым обновлением; остальные не должны ждать его.
+		for _, symbol := range []string{"A", "B", "C", "D"} {
+			queueUpdate("fake", updates, exchange.KlineUpdate{StreamKey: exchange.StreamKey{Symbol: symbol, Timeframe: "60"}})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("queueUpdate() blocked while the consumer was busy")
+	}
+
+	close(c.release)
+	for _, want := range []string{"A", "B"} {
+		if got := <-c.got; got != want {
+			t.Fatalf("processed %q, want %q", got, want)
+		}
+	}
+}
+
+func TestStreamLimitsNotificationsPerBar(t *testing.T) {
+	c := &fakeConsumer{maxSignal: 2, processed: map[string]bool{}, signal: func(string) bool { return true }}
+	s := newTestScanner(&fakeExchange{})
+	s.allowed[c] = map[string]bool{"A": true, "B": true, "C": true, "D": true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bar := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	update := func(symbol string, open time.Time) exchange.KlineUpdate {
+		return exchange.KlineUpdate{StreamKey: exchange.StreamKey{Symbol: symbol, Timeframe: "60"}, Candles: []exchange.Candle{{OpenTime: open}}}
+	}
+	updates := make(chan exchange.KlineUpdate, 8)
+	for _, symbol := range []string{"A", "B", "C", "D"} {
+		updates <- update(symbol, bar)
+	}
+	// Новая свеча открывает новое окно; отстающее обновление прошлой свечи считается в нём же.
+	updates <- update("A", bar.Add(time.Hour))
+	updates <- update("B", bar)
+	updates <- update("C", bar.Add(time.Hour))
+	go s.processStream(ctx, []Consumer{c}, updates)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		calls := c.calls
+		c.mu.Unlock()
+		if calls == 7 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls != 7 || c.notified != 4 {
+		t.Fatalf("processed %d updates, notified %d; want 7 and 2 per bar over 2 bars", c.calls, c.notified)
+	}
+}
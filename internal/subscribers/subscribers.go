@@ -0,0 +1,295 @@
+// Package subscribers хранит подписчиков бота вместе с их профилями сигналов
+// (режим, таймфреймы, пороги и фильтры символов).
+package subscribers
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+// Режимы сигнала профиля.
+const (
+	ModeUpper = "upper"
+	ModeLower = "lower"
+	ModeBoth  = "both"
+)
+
+// Profile — настройки сигналов отдельного чата.
+type Profile struct {
+	Mode           string   `json:"mode"`                      // upper, lower или both
+	Timeframes     []string `json:"timeframes"`                // таймфреймы Bybit, по которым чат получает сигналы
+	RSIUpper       float64  `json:"rsi_upper"`                 // порог RSI для верхней зоны
+	RSILower       float64  `json:"rsi_lower"`                 // порог RSI для нижней зоны
+	StochUpper     float64  `json:"stoch_upper"`               // порог Stoch RSI %K для верхней зоны
+	StochLower     float64  `json:"stoch_lower"`               // порог Stoch RSI %K для нижней зоны
+	Symbols        []string `json:"symbols,omitempty"`         // если задан — только эти символы
+	ExcludeSymbols []string `json:"exclude_symbols,omitempty"` // эти символы не присылаются никогда
+}
+
+// Normalize приводит профиль к допустимому виду и заполняет пропущенные пороги.
+func (p *Profile) Normalize(fallback Profile) {
+	switch p.Mode {
+	case ModeUpper, ModeLower, ModeBoth:
+	default:
+		p.Mode = fallback.Mode
+	}
+	if len(p.Timeframes) == 0 {
+		p.Timeframes = append([]string(nil), fallback.Timeframes...)
+	}
+	if p.RSIUpper <= 0 || p.RSIUpper > 100 {
+		p.RSIUpper = fallback.RSIUpper
+	}
+	if p.RSILower <= 0 || p.RSILower > 100 {
+		p.RSILower = fallback.RSILower
+	}
+	if p.StochUpper <= 0 || p.StochUpper > 100 {
+		p.StochUpper = fallback.StochUpper
+	}
+	if p.StochLower < 0 || p.StochLower > 100 {
+		p.StochLower = fallback.StochLower
+	}
+	p.Symbols = normalizeSymbols(p.Symbols)
+	p.ExcludeSymbols = normalizeSymbols(p.ExcludeSymbols)
+}
+
+// HasTimeframe сообщает, подписан ли профиль на таймфрейм.
+func (p Profile) HasTimeframe(timeframe string) bool {
+	for _, tf := range p.Timeframes {
+		if tf == timeframe {
+			return true
+		}
+	}
+	return false
+}
+
+// Watches сообщает, интересен ли профилю символ на таймфрейме.
+func (p Profile) Watches(timeframe, symbol string) bool {
+	if !p.HasTimeframe(timeframe) {
+		return false
+	}
+	if containsSymbol(p.ExcludeSymbols, symbol) {
+		return false
+	}
+	return len(p.Symbols) == 0 || containsSymbol(p.Symbols, symbol)
+}
+
+// Evaluate проверяет значения индикаторов по порогам профиля и возвращает сработавший режим.
+// kSlack — допуск сглаженного %K над нижним порогом: после SMA линия %K часто остается
+// чуть выше нуля даже при raw Stoch RSI = 0. ruleResults — результаты правил из конфига
+// по режимам: для режима с правилом пороги профиля не используются.
+func (p Profile) Evaluate(values rsi.StochRSIValues, kSlack float64, ruleResults map[string]bool) (string, bool) {
+	if p.Mode != ModeLower {
+		matched, ok := ruleResults[ModeUpper]
+		if !ok {
+			matched = values.RSI >= p.RSIUpper && values.K >= p.StochUpper
+		}
+		if matched {
+			return ModeUpper, true
+		}
+	}
+	if p.Mode != ModeUpper {
+		matched, ok := ruleResults[ModeLower]
+		if !ok {
+			// Касание низа валидно, если raw уже на пороге или сглаженный %K визуально остается у пола.
+			matched = values.RSI <= p.RSILower && (values.RawK <= p.StochLower || values.K <= p.StochLower+kSlack)
+		}
+		if matched {
+			return ModeLower, true
+		}
+	}
+	return "", false
+}
+
+// Store — потокобезопасное хранилище подписчиков с сохранением в JSON-файл.
+type Store struct {
+	path     string
+	defaults func() Profile
+	mu       sync.RWMutex
+	subs     map[int64]Profile
+}
+
+// NewStore создаёт хранилище для файла path; defaults возвращает профиль для новых подписчиков.
+func NewStore(path string, defaults func() Profile) *Store {
+	return &Store{
+		path:     path,
+		defaults: defaults,
+		subs:     make(map[int64]Profile),
+	}
+}
+
+// Load читает файл подписчиков. Поддерживается и старый формат {"chatID": true}:
+// такие подписчики получают профиль по умолчанию.
+func (s *Store) Load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var raw map[int64]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	fallback := s.defaults()
+	subs := make(map[int64]Profile, len(raw))
+	for chatID, item := range raw {
+		item = bytes.TrimSpace(item)
+		var p Profile
+		switch {
+		case bytes.Equal(item, []byte("true")):
+			p = fallback
+		case bytes.Equal(item, []byte("false")), bytes.Equal(item, []byte("null")):
+			continue
+		default:
+			if err := json.Unmarshal(item, &p); err != nil {
+				return err
+			}
+		}
+		p.Normalize(fallback)
+		subs[chatID] = p
+	}
+	s.mu.Lock()
+	s.subs = subs
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) save() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, err := json.MarshalIndent(s.subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// Len возвращает число подписчиков.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.subs)
+}
+
+// All возвращает копию подписчиков с профилями.
+func (s *Store) All() map[int64]Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int64]Profile, len(s.subs))
+	for k, v := range s.subs {
+		out[k] = v
+	}
+	return out
+}
+
+// Get возвращает профиль подписчика.
+func (s *Store) Get(chatID int64) (Profile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.subs[chatID]
+	return p, ok
+}
+
+// Timeframes возвращает объединение таймфреймов всех подписчиков.
+func (s *Store) Timeframes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	var out []string
+	for _, p := range s.subs {
+		for _, tf := range p.Timeframes {
+			if !seen[tf] {
+				seen[tf] = true
+				out = append(out, tf)
+			}
+		}
+	}
+	return out
+}
+
+// Subscribe добавляет подписчика с профилем по умолчанию. Возвращает false, если он уже подписан.
+func (s *Store) Subscribe(chatID int64) (bool, error) {
+	s.mu.Lock()
+	if _, exists := s.subs[chatID]; exists {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.subs[chatID] = s.defaults()
+	s.mu.Unlock()
+	return true, s.save()
+}
+
+// Unsubscribe удаляет подписчика. Возвращает false, если его не было.
+func (s *Store) Unsubscribe(chatID int64) (bool, error) {
+	s.mu.Lock()
+	_, exists := s.subs[chatID]
+	if exists {
+		delete(s.subs, chatID)
+	}
+	s.mu.Unlock()
+	if !exists {
+		return false, nil
+	}
+	return true, s.save()
+}
+
+// Migrate переносит профиль подписчика на новый chat_id, когда группа становится супергруппой.
+// Если новый чат уже подписан, его профиль сохраняется, а старая запись просто удаляется.
+// Возвращает false, если старый чат не был подписан.
+func (s *Store) Migrate(from, to int64) (bool, error) {
+	s.mu.Lock()
+	p, exists := s.subs[from]
+	if exists {
+		delete(s.subs, from)
+		if _, taken := s.subs[to]; !taken {
+			s.subs[to] = p
+		}
+	}
+	s.mu.Unlock()
+	if !exists {
+		return false, nil
+	}
+	return true, s.save()
+}
+
+// Update изменяет профиль подписчика и сохраняет файл. Возвращает false, если чат не подписан.
+func (s *Store) Update(chatID int64, updater func(*Profile)) (bool, error) {
+	s.mu.Lock()
+	p, exists := s.subs[chatID]
+	if !exists {
+		s.mu.Unlock()
+		return false, nil
+	}
+	updater(&p)
+	p.Normalize(s.defaults())
+	s.subs[chatID] = p
+	s.mu.Unlock()
+	return true, s.save()
+}
+
+func normalizeSymbols(symbols []string) []string {
+	var out []string
+	for _, sym := range symbols {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if sym == "" || containsSymbol(out, sym) {
+			continue
+		}
+		out = append(out, sym)
+	}
+	return out
+}
+
+func containsSymbol(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
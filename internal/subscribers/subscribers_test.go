@@ -0,0 +1,74 @@
+package subscribers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grevtsevalex/crypto-bot/internal/rsi"
+)
+
+func testDefaults() Profile {
+	return Profile{
+		Mode:       ModeUpper,
+		Timeframes: []string{"60"},
+		RSIUpper:   70,
+		RSILower:   30,
+		StochUpper: 99.99,
+		StochLower: 0,
+	}
+}
+
+func TestLoadLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	data := `{"1": true, "2": {"mode": "lower", "timeframes": ["240"], "symbols": ["btcusdt"]}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(path, testDefaults)
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	legacy, ok := store.Get(1)
+	if !ok || legacy.Mode != ModeUpper || !legacy.HasTimeframe("60") {
+		t.Fatalf("legacy profile = %+v, want defaults", legacy)
+	}
+	custom, ok := store.Get(2)
+	if !ok || custom.Mode != ModeLower || custom.RSILower != 30 {
+		t.Fatalf("custom profile = %+v", custom)
+	}
+	if !custom.Watches("240", "BTCUSDT") || custom.Watches("240", "ETHUSDT") || custom.Watches("60", "BTCUSDT") {
+		t.Fatalf("custom profile filters are wrong: %+v", custom)
+	}
+}
+
+func TestProfileEvaluate(t *testing.T) {
+	p := testDefaults()
+	p.Mode = ModeBoth
+
+	if mode, ok := p.Evaluate(rsi.StochRSIValues{RSI: 75, K: 100}, 1, nil); !ok || mode != ModeUpper {
+		t.Fatalf("Evaluate(upper) = %q, %v", mode, ok)
+	}
+	if mode, ok := p.Evaluate(rsi.StochRSIValues{RSI: 25, RawK: 5, K: 0.5}, 1, nil); !ok || mode != ModeLower {
+		t.Fatalf("Evaluate(lower) = %q, %v", mode, ok)
+	}
+	if _, ok := p.Evaluate(rsi.StochRSIValues{RSI: 50, K: 50}, 1, nil); ok {
+		t.Fatal("Evaluate(neutral) should not signal")
+	}
+	if _, ok := p.Evaluate(rsi.StochRSIValues{RSI: 25, RawK: 5, K: 0.5}, 0, nil); ok {
+		t.Fatal("Evaluate(lower) without slack should require K at the floor")
+	}
+
+	if mode, ok := p.Evaluate(rsi.StochRSIValues{RSI: 50, K: 50}, 1, map[string]bool{ModeLower: true}); !ok || mode != ModeLower {
+		t.Fatalf("Evaluate() with lower rule result = %q, %v, want lower", mode, ok)
+	}
+	if _, ok := p.Evaluate(rsi.StochRSIValues{RSI: 75, K: 100}, 1, map[string]bool{ModeUpper: false}); ok {
+		t.Fatal("failed upper rule should override thresholds")
+	}
+
+	p.Mode = ModeUpper
+	if _, ok := p.Evaluate(rsi.StochRSIValues{RSI: 25, RawK: 0, K: 0}, 1, nil); ok {
+		t.Fatal("upper profile should ignore lower zone")
+	}
+}
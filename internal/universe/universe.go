@@ -0,0 +1,96 @@
+// Package universe отбирает пары, которые сканирует бот: по обороту за сутки, валюте котировки,
+// возрасту листинга и явным спискам символов.
+package universe
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter — фильтры пар одного бота. Нулевое значение пропускает все пары.
+type Filter struct {
+	MinTurnover24h float64  `json:"min_turnover_24h,omitempty"` // минимальный оборот за 24 часа в валюте котировки
+	QuoteCoins     []string `json:"quote_coins,omitempty"`      // только контракты с этими котировками, например USDT
+	MinListingDays int      `json:"min_listing_days,omitempty"` // не раньше чем через столько дней после листинга
+	MinBars        int      `json:"min_bars,omitempty"`         // не считать сигнал, пока у пары меньше свечей
+	Include        []string `json:"include,omitempty"`          // эти символы сканируются всегда, в обход остальных фильтров
+	Exclude        []string `json:"exclude,omitempty"`          // эти символы не сканируются никогда
+}
+
+// Pair — сведения о паре, по которым работает фильтр.
+type Pair struct {
+	Symbol      string
+	QuoteCoin   string    // пусто — котировка определяется по окончанию символа
+	LaunchTime  time.Time // нулевое — дата листинга неизвестна, фильтр возраста пару пропускает
+	Turnover24h float64
+}
+
+// Normalize приводит символы и монеты к верхнему регистру, как их отдают биржи.
+func (f *Filter) Normalize() {
+	for _, list := range [][]string{f.QuoteCoins, f.Include, f.Exclude} {
+		for i, s := range list {
+			list[i] = strings.ToUpper(strings.TrimSpace(s))
+		}
+	}
+}
+
+// Validate проверяет, что пороги неотрицательны.
+func (f Filter) Validate() error {
+	if f.MinTurnover24h < 0 || f.MinListingDays < 0 || f.MinBars < 0 {
+		return errors.New("min_turnover_24h, min_listing_days и min_bars не могут быть отрицательными")
+	}
+	return nil
+}
+
+// NeedsTurnover сообщает, нужен ли фильтру суточный оборот пар.
+func (f Filter) NeedsTurnover() bool {
+	return f.MinTurnover24h > 0
+}
+
+// NeedsMarketInfo сообщает, нужны ли фильтру данные, которые отдаёт не каждая биржа.
+func (f Filter) NeedsMarketInfo() bool {
+	return f.MinTurnover24h > 0 || f.MinListingDays > 0
+}
+
+// Allows сообщает, сканировать ли пару. Exclude важнее Include, Include — важнее остальных фильтров.
+func (f Filter) Allows(p Pair, now time.Time) bool {
+	if slices.Contains(f.Exclude, p.Symbol) {
+		return false
+	}
+	if slices.Contains(f.Include, p.Symbol) {
+		return true
+	}
+	if len(f.QuoteCoins) > 0 && !f.quoteAllowed(p) {
+		return false
+	}
+	if f.MinTurnover24h > 0 && p.Turnover24h < f.MinTurnover24h {
+		return false
+	}
+	if f.MinListingDays > 0 && !p.LaunchTime.IsZero() &&
+		now.Sub(p.LaunchTime) < time.Duration(f.MinListingDays)*24*time.Hour {
+		return false
+	}
+	return true
+}
+
+// EnoughBars сообщает, хватает ли паре истории для сигнала. Символы из Include проходят
+// и с короткой историей: они сканируются в обход всех фильтров, включая MinBars.
+func (f Filter) EnoughBars(symbol string, bars int) bool {
+	return bars >= f.MinBars || slices.Contains(f.Include, symbol)
+}
+
+func (f Filter) quoteAllowed(p Pair) bool {
+	if p.QuoteCoin != "" {
+		return slices.Contains(f.QuoteCoins, p.QuoteCoin)
+	}
+	// Binance отдаёт BTCUSDT, OKX — BTC-USDT-SWAP.
+	symbol := strings.TrimSuffix(p.Symbol, "-SWAP")
+	for _, q := range f.QuoteCoins {
+		if strings.HasSuffix(symbol, q) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package universe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterAllows(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	f := Filter{
+		MinTurnover24h: 1_000_000,
+		QuoteCoins:     []string{"usdt"},
+		MinListingDays: 30,
+		Include:        []string{"newusdt"},
+		Exclude:        []string{"btcusdt"},
+	}
+	f.Normalize()
+
+	liquid := Pair{Symbol: "ETHUSDT", QuoteCoin: "USDT", LaunchTime: now.AddDate(-1, 0, 0), Turnover24h: 5e8}
+	for _, c := range []struct {
+		name string
+		pair Pair
+		want bool
+	}{
+		{"liquid old pair", liquid, true},
+		{"excluded", Pair{Symbol: "BTCUSDT", QuoteCoin: "USDT", Turnover24h: 1e10}, false},
+		{"included bypasses filters", Pair{Symbol: "NEWUSDT", QuoteCoin: "USDT", LaunchTime: now, Turnover24h: 10}, true},
+		{"low turnover", Pair{Symbol: "LOWUSDT", QuoteCoin: "USDT", Turnover24h: 50_000}, false},
+		{"other quote", Pair{Symbol: "ETHPERP", QuoteCoin: "USDC", Turnover24h: 5e8}, false},
+		{"fresh listing", Pair{Symbol: "FRESHUSDT", QuoteCoin: "USDT", LaunchTime: now.AddDate(0, 0, -3), Turnover24h: 5e8}, false},
+		{"unknown launch time", Pair{Symbol: "OLDUSDT", QuoteCoin: "USDT", Turnover24h: 5e8}, true},
+	} {
+		if got := f.Allows(c.pair, now); got != c.want {
+			t.Errorf("%s: Allows(%+v) = %v, want %v", c.name, c.pair, got, c.want)
+		}
+	}
+
+	if !(Filter{}).Allows(Pair{Symbol: "ANY"}, now) {
+		t.Fatal("zero filter rejected a pair")
+	}
+}
+
+func TestFilterQuoteFromSymbol(t *testing.T) {
+	f := Filter{QuoteCoins: []string{"USDT"}}
+	for symbol, want := range map[string]bool{"BTCUSDT": true, "BTC-USDT-SWAP": true, "BTCUSDC": false, "BTC-USD-SWAP": false} {
+		if got := f.Allows(Pair{Symbol: symbol}, time.Now()); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", symbol, got, want)
+		}
+	}
+}
+
+func TestFilterEnoughBars(t *testing.T) {
+	f := Filter{MinBars: 100, Include: []string{"newusdt"}}
+	f.Normalize()
+	for _, c := range []struct {
+		symbol string
+		bars   int
+		want   bool
+	}{
+		{"ETHUSDT", 100, true},
+		{"ETHUSDT", 99, false},
+		{"NEWUSDT", 10, true},
+	} {
+		if got := f.EnoughBars(c.symbol, c.bars); got != c.want {
+			t.Errorf("EnoughBars(%s, %d) = %v, want %v", c.symbol, c.bars, got, c.want)
+		}
+	}
+}
@@ -1,203 +1,125 @@
-// Пакет main — точка входа бота: RSI/Stoch RSI на выбранном таймфрейме Bybit,
-// уведомление при достижении порога по линии Stoch RSI %K.
+// Пакет main — точка входа: запускает один или несколько Telegram-ботов RSI/Stoch RSI
+// в одном процессе с общим сканером свечей Bybit.
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
-	"sync"
-	"time"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 
+	"grevtsevalex/crypto-bot/internal/bot"
 	"grevtsevalex/crypto-bot/internal/config"
 	"grevtsevalex/crypto-bot/internal/exchange"
-	"grevtsevalex/crypto-bot/internal/handlers"
-	"grevtsevalex/crypto-bot/internal/notify"
-	"grevtsevalex/crypto-bot/internal/rsi"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-)
-
-const (
-	canonicalRSIPeriod         = 14
-	canonicalStochPeriod       = 14
-	canonicalSmoothK           = 3
-	canonicalSmoothD           = 3
-	canonicalRSIUpperThreshold = 70.0
-	canonicalRSILowerThreshold = 30.0
-	canonicalStochUpperLevel   = 99.99
-	canonicalStochLowerLevel   = 0.0
-	canonicalStochLowerKSlack  = 1.0
+	"grevtsevalex/crypto-bot/internal/scanner"
 )
 
-var (
-	bot           *tgbotapi.BotAPI
-	notifier      *notify.Notifier
-	subscribers   = make(map[int64]bool)
-	subscribersMu sync.RWMutex
-)
+// configList — флаг -config, который можно указать несколько раз.
+type configList []string
 
-func getSubscribersFile() string {
-	return config.Get().SubscribersFile
+func (l *configList) String() string {
+	return strings.Join(*l, ",")
 }
 
-func loadSubscribers() error {
-	path := getSubscribersFile()
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return err
-	}
-	subscribersMu.Lock()
-	defer subscribersMu.Unlock()
-	return json.Unmarshal(data, &subscribers)
-}
-
-func saveSubscribers() error {
-	subscribersMu.RLock()
-	defer subscribersMu.RUnlock()
-	data, err := json.MarshalIndent(subscribers, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(getSubscribersFile(), data, 0644)
+func (l *configList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-func getSubscribers() map[int64]bool {
-	subscribersMu.RLock()
-	defer subscribersMu.RUnlock()
-	out := make(map[int64]bool, len(subscribers))
-	for k, v := range subscribers {
-		out[k] = v
-	}
-	return out
-}
-
-func subscribe(chatID int64) {
-	subscribersMu.Lock()
-	subscribers[chatID] = true
-	subscribersMu.Unlock()
-	if err := saveSubscribers(); err != nil {
-		log.Printf("Ошибка сохранения подписчиков: %v", err)
-	}
-}
-
-func unsubscribe(chatID int64) {
-	subscribersMu.Lock()
-	_, exists := subscribers[chatID]
-	if exists {
-		delete(subscribers, chatID)
-	}
-	subscribersMu.Unlock()
-	if exists {
-		if err := saveSubscribers(); err != nil {
-			log.Printf("Ошибка сохранения подписчиков: %v", err)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		if err := runBacktest(os.Args[2:]); err != nil {
+			log.Fatalf("Ошибка бэктеста: %v", err)
 		}
-		log.Printf("Пользователь отписался: %d", chatID)
+		return
+	}
+	// Процесс завершается только здесь: отложенные вызовы run, в том числе удаление
+	// pid-файла, которого ждёт make restart, выполняются и при ошибке запуска.
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func main() {
-	configPath := flag.String("config", "config.json", "path to config file")
+// run запускает ботов и сканер и возвращает управление после сигнала остановки.
+func run() error {
+	var configPaths configList
+	flag.Var(&configPaths, "config", "path to config file or directory with configs (repeatable)")
+	pidFile := flag.String("pidfile", "", "write process id to this file")
+	candleCache := flag.String("candle-cache", "", "directory to keep candle cache between restarts")
+	workers := flag.Int("workers", scanner.DefaultWorkers, "concurrent candle requests per exchange")
 	flag.Parse()
-
-	if err := config.Load(*configPath); err != nil {
-		log.Fatalf("Ошибка загрузки конфига: %v", err)
-	}
-	cfg := config.Get()
-	if cfg.TelegramToken == "" {
-		log.Fatalf("Укажите telegram_token в %s", *configPath)
+	if *workers < 1 {
+		return fmt.Errorf("-workers должен быть не меньше 1, получено %d", *workers)
 	}
-
-	if err := loadSubscribers(); err != nil {
-		log.Printf("Ошибка загрузки подписчиков: %v", err)
-	} else {
-		log.Printf("Загружено %d подписчиков", len(subscribers))
+	configPaths = append(configPaths, flag.Args()...)
+	if len(configPaths) == 0 {
+		configPaths = configList{"config.json"}
 	}
 
-	botApi, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	stores, err := config.LoadAll(configPaths)
 	if err != nil {
-		log.Fatal("Ошибка инициализации бота:", err)
+		return fmt.Errorf("ошибка загрузки конфига: %w", err)
+	}
+	if len(stores) == 0 {
+		return fmt.Errorf("не найдено ни одного конфига в %s", configPaths.String())
 	}
-	bot = botApi
-	notifier = notify.New(botApi, cfg.SignalMode, getSubscribers)
-
-	h := handlers.New(bot, cfg.SignalMode, getSubscribers, subscribe, unsubscribe)
-	go h.HandleUpdates()
-
-	for {
-		log.Printf("Запуск анализа рынка (%s, mode=%s)...", config.Get().Timeframe, config.Get().SignalMode)
 
-		symbols, err := exchange.DerivativePairs()
-		if err != nil {
-			log.Printf("Ошибка получения пар: %v", err)
-			time.Sleep(1 * time.Minute)
-			continue
+	if *pidFile != "" {
+		if err := os.WriteFile(*pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			return fmt.Errorf("ошибка записи pid-файла: %w", err)
 		}
+		defer os.Remove(*pidFile)
+	}
 
-		loopCfg := config.Get()
-		maxPer := loopCfg.MaxSignalsPerCycle
-		if maxPer <= 0 {
-			maxPer = 10
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Один адаптер на биржу на весь процесс: сканер, команды и трекеры результатов всех ботов
+	// делят его ограничитель запросов и кэш списка контрактов.
+	exchanges := make(map[string]exchange.Exchange)
+	var consumers []scanner.Consumer
+	var bots []*bot.Bot
+	// Уже запущенные боты останавливаются и при ошибке запуска следующих: их состояние сохраняется.
+	defer func() {
+		for _, b := range bots {
+			b.Stop()
 		}
-		sentThisCycle := 0
-		for _, symbol := range symbols {
-			if sentThisCycle >= maxPer {
-				break
+		log.Printf("Остановлено ботов: %d", len(bots))
+	}()
+	for _, store := range stores {
+		name := store.Get().Exchange
+		ex, ok := exchanges[name]
+		if !ok {
+			if ex, err = exchange.New(ctx, name); err != nil {
+				return fmt.Errorf("ошибка запуска бота %s: %w", store.Path(), err)
 			}
-			if processSymbol(symbol) {
-				sentThisCycle++
-			}
-			time.Sleep(100 * time.Millisecond)
+			exchanges[name] = ex
 		}
-
-		log.Println("Анализ завершён. Следующий запуск через 1 минуту...")
-		time.Sleep(1 * time.Minute)
+		b, err := bot.New(store, ex)
+		if err != nil {
+			return fmt.Errorf("ошибка запуска бота: %w", err)
+		}
+		b.Start()
+		bots = append(bots, b)
+		consumers = append(consumers, b)
+		log.Printf("Бот %s запущен", b.Name())
 	}
-}
 
-// processSymbol запрашивает свечи выбранного таймфрейма, считает Bybit-подобные RSI и Stoch RSI
-// и шлёт уведомление при достижении порога выбранного режима.
-// Возвращает true, если уведомление было отправлено.
-func processSymbol(symbol string) bool {
-	cfg := config.Get()
-	limit := cfg.CandleLimit
-	if limit < 50 {
-		limit = 100
-	}
-	closes, err := exchange.Candles(symbol, cfg.Timeframe, limit)
+	sc, err := scanner.New(consumers, exchanges)
 	if err != nil {
-		log.Printf("Ошибка свечей %s: %v", symbol, err)
-		return false
-	}
-
-	values := rsi.CalcStochRSI(closes, canonicalRSIPeriod, canonicalStochPeriod, canonicalSmoothK, canonicalSmoothD)
-	log.Printf(
-		"%s RSI(%s,%d)=%.2f Stoch RSI(%d,%d,%d) raw=%.2f K=%.2f D=%.2f",
-		symbol, cfg.Timeframe, canonicalRSIPeriod, values.RSI, canonicalStochPeriod, canonicalSmoothK, canonicalSmoothD, values.RawK, values.K, values.D,
-	)
-
-	if shouldSignal(cfg.SignalMode, values.RSI, values.RawK, values.K) {
-		notifier.SendSignal(symbol, cfg.Timeframe, values.RSI, values.K, canonicalRSIPeriod, canonicalStochPeriod, canonicalSmoothK, canonicalSmoothD)
-		return true
+		return fmt.Errorf("ошибка запуска сканера: %w", err)
 	}
-	notifier.ClearSignalState(symbol)
-
-	return false
-}
-
-func shouldSignal(signalMode string, rsiValue, rawKValue, kValue float64) bool {
-	if signalMode == "lower" {
-		if rsiValue > canonicalRSILowerThreshold {
-			return false
+	sc.Workers = *workers
+	if *candleCache != "" {
+		if err := sc.LoadCandleCache(*candleCache); err != nil {
+			return fmt.Errorf("ошибка загрузки кэша свечей: %w", err)
 		}
-		// После SMA(3) линия %K часто остается чуть выше нуля даже при raw Stoch RSI = 0.
-		// Для нижнего сигнала считаем касание низа валидным, если raw уже на нуле
-		// или сглаженный %K визуально остается у пола.
-		return rawKValue <= canonicalStochLowerLevel || kValue <= canonicalStochLowerKSlack
 	}
-	return rsiValue >= canonicalRSIUpperThreshold && kValue >= canonicalStochUpperLevel
+	sc.Run(ctx)
+	return nil
 }